@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"time"
 
 	yaml "gopkg.in/mikefarah/yaml.v2"
@@ -17,19 +18,57 @@ const (
 	contentType     string = "Content-Type"
 	applicationJSON string = "application/json; charset=utf-8"
 	userAgent       string = "kongfig"
-)
 
-var (
-	// Keeps track of route names to route IDs
-	// Used in the creation of plugins for specific routes
-	routeMap = make(map[string]string)
+	defaultSnapshotDir string = ".kongfig/snapshots"
 )
 
+func init() {
+	// Decode nested YAML maps the same way as JSON, so they can be sent to Kong
+	yaml.DefaultMapType = reflect.TypeOf(map[string]interface{}{})
+}
+
 // Client represents the public API
 type Client struct {
 	config  *Config
 	client  *http.Client
 	BaseURL string
+	// Cluster is the name of the cluster of the config the client reaches, empty when the config has a single host
+	Cluster string
+
+	// Keeps track of route names to route IDs
+	// Used in the creation of plugins for specific routes
+	routeIDs map[string]string
+
+	// SnapshotDir is where the state of Kong is saved before applying a config
+	SnapshotDir string
+	// DisableRollback skips restoring the snapshot when applying a config fails
+	DisableRollback bool
+	// JournalDir is where the operations of every apply and their progress are recorded
+	JournalDir string
+	// LockTimeout is how long the apply lock is held before other applies may take it over
+	LockTimeout time.Duration
+	// LockOwner identifies who holds the apply lock, the user, host and pid by default
+	LockOwner string
+	// ForceUnlock takes the apply lock over even when another apply holds it
+	ForceUnlock bool
+	// DisableLock applies without holding the apply lock
+	DisableLock bool
+	// CreateWorkspace creates the workspace of the config before applying when it is missing from Kong
+	CreateWorkspace bool
+	// Selector restricts applies and diffs to some entities, every entity when nil
+	Selector *Selector
+	// DeletionPolicy controls which entities missing from the config are deleted, all of them when nil
+	DeletionPolicy *DeletionPolicy
+	// Approve is asked to confirm the plan of an apply before anything is changed, eg: by prompting the user
+	Approve func(plan *Plan) (bool, error)
+	// SchemaDir is where the plugin and credential schemas of Kong are cached
+	SchemaDir string
+	// DisableValidation skips validating plugin and credential configs before applying
+	DisableValidation bool
+	// Reporter receives an event for every operation performed against Kong
+	Reporter Reporter
+	// Logger receives diagnostic messages
+	Logger Logger
 }
 
 // httpRequest is an utility method for executing HTTP requests
@@ -43,6 +82,12 @@ func (c *Client) httpRequest(method, url string, payload []byte, response interf
 	req.Header.Set(contentType, applicationJSON)
 	req.Header.Set("User-Agent", userAgent)
 
+	if c.config != nil {
+		for name, value := range c.config.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+
 	res, err := c.client.Do(req)
 
 	if err != nil {
@@ -63,13 +108,29 @@ func NewClient(filePath string) (*Client, error) {
 		return nil, err
 	}
 
-	c := &Client{
-		config:  config,
-		client:  &http.Client{Timeout: time.Duration(5 * time.Second)},
-		BaseURL: adminURL(config),
+	return NewConfigClient(config), nil
+}
+
+// NewConfigClient returns a Client for an already parsed config, eg: from ParseConfig
+func NewConfigClient(config *Config) *Client {
+	return &Client{
+		config:      config,
+		client:      &http.Client{Timeout: time.Duration(5 * time.Second)},
+		BaseURL:     adminURL(config),
+		routeIDs:    make(map[string]string),
+		SnapshotDir: defaultSnapshotDir,
+		JournalDir:  defaultJournalDir,
+		LockTimeout: defaultLockTimeout,
+		LockOwner:   lockOwner(),
+		SchemaDir:   defaultSchemaDir,
+		Reporter:    &textReporter{w: os.Stdout},
+		Logger:      NewLogger(os.Stderr, LevelInfo),
 	}
+}
 
-	return c, nil
+// LoadConfig parses the config file at path, expanding environment variables
+func LoadConfig(path string) (*Config, error) {
+	return configFromPath(path)
 }
 
 // configFromPath parses the YAML file specified in the path param
@@ -82,14 +143,43 @@ func configFromPath(path string) (*Config, error) {
 
 	configData = []byte(os.ExpandEnv(string(configData)))
 
-	c := Config{}
+	return ParseConfig(configData)
+}
 
-	yaml.DefaultMapType = reflect.TypeOf(map[string]interface{}{})
-	if err := yaml.Unmarshal(configData, &c); err != nil {
+// ParseConfig parses a config from YAML, without expanding environment variables
+func ParseConfig(data []byte) (*Config, error) {
+	c, err := ParseRawConfig(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkClusters(c); err != nil {
+		return nil, err
+	}
+
+	if err := flattenNested(c); err != nil {
+		return nil, err
+	}
+
+	if err := resolveTemplates(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ParseRawConfig parses a config from YAML as written, keeping its nested
+// routes and plugins and the plugins extending templates, so it can be edited
+// and written back
+func ParseRawConfig(data []byte) (*Config, error) {
+	c := &Config{}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
 		return nil, err
 	}
 
-	return &c, nil
+	return c, nil
 }
 
 func adminURL(c *Config) string {
@@ -99,108 +189,190 @@ func adminURL(c *Config) string {
 		protocol = "https"
 	}
 
-	return fmt.Sprintf("%s://%s", protocol, c.Host)
+	return fmt.Sprintf("%s://%s%s", protocol, c.Host, workspacePath(c.Workspace))
 }
 
-// ApplyConfig iterates through all services and updates config, deletes and recreates routes
+// ApplyConfig snapshots the current state of Kong and applies the config,
+// recording the planned operations and their progress in a journal. The plan
+// is confirmed with Approve when set, and if anything fails along the way Kong
+// is restored to the snapshot. The apply lock is held meanwhile, so concurrent
+// applies fail instead of interleaving. The workspace of the config is created
+// first when CreateWorkspace is set. When Kong runs in DB-less mode the config
+// is loaded as a whole instead
 func (c *Client) ApplyConfig() error {
+	c.Logger.Infof("Applying config to %s", c.BaseURL)
 
-	if len(c.config.Credentials) > 0 {
-		if err := c.DeleteConsumers(); err != nil { //Deleting consumers deletes credentials as well
+	if !c.DisableValidation {
+		if err := c.validateBeforeApply(); err != nil {
 			return err
 		}
 	}
 
-	if err := c.DeletePlugins(); err != nil {
+	dbless, err := c.DBLess()
+
+	if err != nil {
 		return err
 	}
 
-	if err := c.DeleteRoutes(); err != nil {
-		return err
+	if dbless {
+		if c.workspace() != "" {
+			return fmt.Errorf("Kong runs in DB-less mode, which doesn't support workspaces")
+		}
+
+		if !c.Selector.Empty() {
+			return fmt.Errorf("Kong runs in DB-less mode, where configs are loaded as a whole and entities can't be selected")
+		}
+
+		c.Logger.Infof("Kong runs in DB-less mode, loading the config through /config")
+
+		return c.ApplyDeclarative()
 	}
 
-	if err := c.DeleteServices(); err != nil {
+	// The apply lock is held in the workspace
+	if err := c.ensureWorkspace(); err != nil {
 		return err
 	}
 
-	for _, s := range c.config.Services {
-		if err := c.UpdateService(s); err != nil {
+	if !c.DisableLock {
+		lock, err := c.AcquireLock()
+
+		if err != nil {
 			return err
 		}
+
+		defer lock.Release()
 	}
 
-	if err := c.CreateRoutes(); err != nil {
-		return err
+	snapshot, err := c.TakeSnapshot()
+
+	if err != nil {
+		return fmt.Errorf("Error taking snapshot: %s", err)
 	}
 
-	if err := c.CreatePlugins(); err != nil {
+	plan, err := c.plan(snapshot)
+
+	if err != nil {
 		return err
 	}
 
-	if len(c.config.Credentials) > 0 {
-		if err := c.CreateConsumers(); err != nil {
+	if c.Approve != nil {
+		approved, err := c.Approve(plan)
+
+		if err != nil {
 			return err
 		}
 
-		if err := c.CreateCredentials(); err != nil {
-			return err
+		if !approved {
+			return fmt.Errorf("Apply cancelled, nothing was changed")
 		}
 	}
 
-	return nil
+	return c.applyPlan(plan, snapshot)
 }
 
-// UpdateService updates an existing service or creates a new one if it doesn't exist
-// Makes a HTTP PUT to the KONG ADMIN API
-func (c *Client) UpdateService(s Service) error {
-	url := fmt.Sprintf("%s/services/%s", c.BaseURL, s.Name)
+// applyPlan saves the snapshot of Kong and executes a plan computed from it,
+// recording its progress in a journal
+func (c *Client) applyPlan(plan *Plan, snapshot *Snapshot) error {
+	path := ""
 
-	payload, err := json.Marshal(s)
+	err := c.track(KindSnapshot, "current state", ActionSave, func() (int, error) {
+		var err error
+		path, err = snapshot.Save(c.SnapshotDir)
+
+		return 0, err
+	})
 
 	if err != nil {
-		return err
+		return fmt.Errorf("Error saving snapshot: %s", err)
 	}
 
-	res, err := c.httpRequest(http.MethodPut, url, payload, nil)
+	c.Logger.Infof("Snapshot of current state saved to %s", path)
+
+	journal, err := c.newJournal(plan, path)
 
 	if err != nil {
-		fmt.Printf("error: %s \n", err)
-		fmt.Printf("Error updating service: %s \n", s.Name)
+		return fmt.Errorf("Error writing journal: %s", err)
+	}
 
-		return err
+	c.Logger.Debugf("Journal of %d operation(s) saved to %s", len(journal.Operations), journal.Path())
+
+	return c.run(journal, snapshot)
+}
+
+// validateBeforeApply validates the plugin and credential configs against the
+// schemas of Kong, so invalid configs fail before anything is changed
+func (c *Client) validateBeforeApply() error {
+	issues, err := c.ValidatePlugins()
+
+	if err != nil {
+		return fmt.Errorf("Error validating plugin configs: %s", err)
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("[HTTP %d] Error updating service. Bad response from the API", res.StatusCode)
+	for _, i := range issues {
+		c.Logger.Errorf("%s %s: %s", i.Kind, i.Name, i.Message)
 	}
 
-	fmt.Printf("[HTTP %d] Successfully created/updated service: %s \n", http.StatusOK, s.Name)
+	if len(issues) > 0 {
+		return fmt.Errorf("The config has %d invalid plugin or credential attribute(s), nothing was applied", len(issues))
+	}
 
 	return nil
 }
 
-// CreateRoutes iterates through all available routes and creates for the associated service
-func (c *Client) CreateConsumers() error {
-	for _, r := range c.config.Consumers {
-		url := fmt.Sprintf("%s/consumers", c.BaseURL)
+// UpdateService updates an existing service or creates a new one if it doesn't exist
+// Makes a HTTP PUT to the KONG ADMIN API
+func (c *Client) UpdateService(s Service) error {
+	return c.track(KindService, s.Name, ActionUpdate, func() (int, error) {
+		url := fmt.Sprintf("%s/services/%s", c.BaseURL, s.Name)
 
-		payload, err := json.Marshal(r)
+		payload, err := json.Marshal(s)
 
 		if err != nil {
-			return err
+			return 0, err
 		}
 
-		res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+		res, err := c.httpRequest(http.MethodPut, url, payload, nil)
 
 		if err != nil {
-			return err
+			return 0, err
 		}
 
-		if res.StatusCode != http.StatusCreated {
-			return fmt.Errorf("[HTTP %d] Error creating consumer. Bad response from Kong API", res.StatusCode)
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error updating service. Bad response from the API", res.StatusCode)
 		}
 
-		fmt.Printf("[HTTP %d] Consumer %s created\n", res.StatusCode, r.Username)
+		return res.StatusCode, nil
+	})
+}
+
+// CreateConsumers iterates through all available consumers and creates them
+func (c *Client) CreateConsumers() error {
+	for _, r := range c.config.Consumers {
+		err := c.track(KindConsumer, r.Username, ActionCreate, func() (int, error) {
+			url := fmt.Sprintf("%s/consumers", c.BaseURL)
+
+			payload, err := json.Marshal(r)
+
+			if err != nil {
+				return 0, err
+			}
+
+			res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+
+			if err != nil {
+				return 0, err
+			}
+
+			if res.StatusCode != http.StatusCreated {
+				return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating consumer. Bad response from Kong API", res.StatusCode)
+			}
+
+			return res.StatusCode, nil
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -225,20 +397,20 @@ func (c *Client) DeleteServices() error {
 
 // DeleteService deletes a service for a service based on route id
 func (c *Client) DeleteService(r Service) error {
-	url := fmt.Sprintf("%s/services/%s", c.BaseURL, r.Name)
-	res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
+	return c.track(KindService, r.Name, ActionDelete, func() (int, error) {
+		url := fmt.Sprintf("%s/services/%s", c.BaseURL, r.Name)
+		res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
 
-	if err != nil {
-		return err
-	}
-
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("[HTTP %d] Error deleting service. Bad response response from the API", res.StatusCode)
-	}
+		if err != nil {
+			return 0, err
+		}
 
-	fmt.Printf("[HTTP %d] Service [%s] deleted \n", res.StatusCode, r.Name)
+		if res.StatusCode != http.StatusNoContent {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error deleting service. Bad response response from the API", res.StatusCode)
+		}
 
-	return nil
+		return res.StatusCode, nil
+	})
 }
 
 // GetServices fetches all services from Kong
@@ -262,31 +434,43 @@ func (c *Client) GetServices() ([]Service, error) {
 // CreateRoutes iterates through all available routes and creates for the associated service
 func (c *Client) CreateRoutes() error {
 	for _, r := range c.config.Routes {
-		url := fmt.Sprintf("%s/services/%s/routes", c.BaseURL, r.Service)
+		name := routeName(r.Name, r.Service, r.Paths)
 
-		payload, err := json.Marshal(r)
+		err := c.track(KindRoute, name, ActionCreate, func() (int, error) {
+			url := fmt.Sprintf("%s/services/%s/routes", c.BaseURL, r.Service)
 
-		if err != nil {
-			return err
-		}
+			payload, err := json.Marshal(r)
 
-		route := Route{}
-		res, err := c.httpRequest(http.MethodPost, url, payload, &route)
+			if err != nil {
+				return 0, err
+			}
 
-		// Mapping route names to route ids
-		// We do this so that we can create plugins for routes without having to
-		// specific route id each time. It's easier to refer to routes via names
-		routeMap[r.Name] = route.ID
+			route := Route{}
+			res, err := c.httpRequest(http.MethodPost, url, payload, &route)
 
-		if res.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("[HTTP %d] Error creating routes: Service not found", res.StatusCode)
-		}
+			if err != nil {
+				return 0, err
+			}
 
-		if res.StatusCode != http.StatusCreated {
-			return fmt.Errorf("[HTTP %d] Error creating routes. Bad response from Kong API", res.StatusCode)
-		}
+			// Mapping route names to route ids
+			// We do this so that we can create plugins for routes without having to
+			// specific route id each time. It's easier to refer to routes via names
+			c.routeIDs[r.Name] = route.ID
+
+			if res.StatusCode == http.StatusNotFound {
+				return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating routes: Service not found", res.StatusCode)
+			}
+
+			if res.StatusCode != http.StatusCreated {
+				return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating routes. Bad response from Kong API", res.StatusCode)
+			}
+
+			return res.StatusCode, nil
+		})
 
-		fmt.Printf("[HTTP %d] Route created for service %s \n", res.StatusCode, r.Service)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -329,21 +513,27 @@ func (c *Client) DeleteRoutes() error {
 
 // DeleteRoute deletes a route for a service based on route id
 func (c *Client) DeleteRoute(r Route) error {
-	url := fmt.Sprintf("%s/routes/%s", c.BaseURL, r.ID)
-
-	res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
+	name := r.Name
 
-	if err != nil {
-		return err
+	if name == "" {
+		name = r.ID
 	}
 
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("[HTTP %d] Error deleting route. Bad response response from the API", res.StatusCode)
-	}
+	return c.track(KindRoute, name, ActionDelete, func() (int, error) {
+		url := fmt.Sprintf("%s/routes/%s", c.BaseURL, r.ID)
 
-	fmt.Printf("[HTTP %d] Route [%s] deleted \n", res.StatusCode, r.ID)
+		res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
 
-	return nil
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode != http.StatusNoContent {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error deleting route. Bad response response from the API", res.StatusCode)
+		}
+
+		return res.StatusCode, nil
+	})
 }
 
 // GetConsumers fetches all consumers from Kong
@@ -373,6 +563,10 @@ func (c *Client) DeleteConsumers() error {
 	}
 
 	for _, r := range consumers {
+		if r.Username == LockConsumer {
+			continue
+		}
+
 		if err := c.DeleteConsumer(r); err != nil {
 			return err
 		}
@@ -383,117 +577,225 @@ func (c *Client) DeleteConsumers() error {
 
 // DeleteConsumer deletes a consumer for a service based on route id
 func (c *Client) DeleteConsumer(r Consumer) error {
-	url := fmt.Sprintf("%s/consumers/%s", c.BaseURL, r.Username)
-	res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
+	return c.track(KindConsumer, r.Username, ActionDelete, func() (int, error) {
+		url := fmt.Sprintf("%s/consumers/%s", c.BaseURL, r.Username)
+		res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return 0, err
+		}
 
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("[HTTP %d] Error deleting consumer. Bad response response from the API", res.StatusCode)
-	}
+		if res.StatusCode != http.StatusNoContent {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error deleting consumer. Bad response response from the API", res.StatusCode)
+		}
 
-	fmt.Printf("[HTTP %d] Consumer [%s] deleted \n", res.StatusCode, r.Username)
+		return res.StatusCode, nil
+	})
+}
 
-	return nil
+// CreatePlugins creates or updates the plugins of the config
+//
+// Deprecated: use UpsertPlugins
+func (c *Client) CreatePlugins() error {
+	return c.UpsertPlugins()
 }
 
-// CreatePlugins creates global plugins, and plugins for services & routes
+// UpsertPlugins creates global plugins, and plugins for services & routes.
+// Plugins are identified by their name and the service or route they apply
+// to: missing ones are created, the ones whose config differs are
+// updated and identical ones are left untouched
 // Global plugins apply to all services and their routes
 // Service plugins apply to all routes of a service
 // Route plugins apply to only the specified route of a service
-func (c *Client) CreatePlugins() error {
-	for _, plugin := range c.config.Plugins {
-		// Create global plugins
-		if plugin.Target == "global" {
-			url := fmt.Sprintf("%s/plugins", c.BaseURL)
+func (c *Client) UpsertPlugins() error {
+	live, err := c.livePlugins()
 
-			payload, err := json.Marshal(plugin)
+	if err != nil {
+		return err
+	}
 
-			if err != nil {
-				fmt.Println("Error marshalling payload: ", err)
+	for _, plugin := range c.config.Plugins {
+		// Upsert global plugins
+		if plugin.Target == "global" {
+			if err := c.upsertPlugin(plugin, "/plugins", pluginName(plugin.Name, "", ""), live); err != nil {
 				return err
 			}
 
-			res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+			continue
+		}
 
-			if err != nil {
-				fmt.Println("Error creating plugin: ", err)
+		// Upsert plugins for services
+		for _, service := range plugin.Services {
+			path := fmt.Sprintf("/services/%s/plugins", service)
+
+			if err := c.upsertPlugin(plugin, path, pluginName(plugin.Name, service, ""), live); err != nil {
 				return err
 			}
+		}
 
-			if res.StatusCode == http.StatusNotFound {
-				return fmt.Errorf("[HTTP %d] Global plugin already exists", res.StatusCode)
+		// Upsert plugins for routes, Kong also accepts route names when they were not created by this client
+		for _, route := range plugin.Routes {
+			ref := route
+
+			if id, ok := c.routeIDs[route]; ok {
+				ref = id
 			}
 
-			if res.StatusCode != http.StatusCreated {
-				return fmt.Errorf("[HTTP %d] Error creating global plugin. Bad response from Kong API", res.StatusCode)
+			path := fmt.Sprintf("/routes/%s/plugins", ref)
+
+			if err := c.upsertPlugin(plugin, path, pluginName(plugin.Name, "", route), live); err != nil {
+				return err
 			}
+		}
+	}
 
-			fmt.Printf("[HTTP %d] Global plugin created %s \n", res.StatusCode, plugin.Name)
-		} else {
-			// Creating plugins for specific services and routes
-			// Create plugins for services:
-			for _, service := range plugin.Services {
-				url := fmt.Sprintf("%s/services/%s/plugins", c.BaseURL, service)
+	return nil
+}
 
-				payload, err := json.Marshal(plugin)
+// upsertPlugin creates the plugin on the collection at path, or updates the
+// live plugin with the same scope when its config differs
+func (c *Client) upsertPlugin(plugin Plugin, path, name string, live map[string]Entity) error {
+	existing, ok := live[name]
 
-				if err != nil {
-					fmt.Println("Error marshalling payload: ", err)
-					return err
-				}
+	if !ok {
+		return c.createPlugin(plugin, c.BaseURL+path, name)
+	}
 
-				res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+	desired := pluginEntity(plugin)
+	fields := diffFields("", desired, existing, nil, true)
 
-				if err != nil {
-					fmt.Println("Error creating plugin: ", err)
-					return err
-				}
+	if len(fields) == 0 {
+		return c.track(KindPlugin, name, ActionNone, func() (int, error) {
+			return 0, nil
+		})
+	}
 
-				if res.StatusCode != http.StatusCreated {
-					return fmt.Errorf("[HTTP %d] Error creating plugin for service %s. Bad response from Kong API", res.StatusCode, service)
-				}
+	return c.track(KindPlugin, name, updateAction(fields), func() (int, error) {
+		url := fmt.Sprintf("%s/plugins/%s", c.BaseURL, existing.ID())
 
-				fmt.Printf("[HTTP %d] Plugin created for service %s \n", res.StatusCode, service)
-			}
+		payload, err := json.Marshal(desired)
 
-			// Create plugins for routes
-			for _, route := range plugin.Routes {
-				routeID := routeMap[route]
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := c.httpRequest(http.MethodPatch, url, payload, nil)
 
-				url := fmt.Sprintf("%s/routes/%s/plugins", c.BaseURL, routeID)
-				payload, err := json.Marshal(plugin)
+		if err != nil {
+			return 0, err
+		}
 
-				if err != nil {
-					fmt.Println("Error marshalling payload: ", err)
-					return err
-				}
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error updating plugin %s. Bad response from Kong API", res.StatusCode, name)
+		}
 
-				res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+		return res.StatusCode, nil
+	})
+}
 
-				if err != nil {
-					fmt.Println("Error creating plugin: ", err)
-					return err
-				}
+// livePlugins fetches the plugins of Kong, keyed by their name and the
+// service or route they apply to
+func (c *Client) livePlugins() (map[string]Entity, error) {
+	s := &Snapshot{}
 
-				if res.StatusCode == http.StatusNotFound {
-					return fmt.Errorf("[HTTP %d] Error creating plugin. Route not found %s", res.StatusCode, route)
-				}
+	collections := []struct {
+		path     string
+		entities *[]Entity
+	}{
+		{"/services", &s.Services},
+		{"/routes", &s.Routes},
+		{"/consumers", &s.Consumers},
+		{"/plugins", &s.Plugins},
+	}
 
-				if res.StatusCode != http.StatusCreated {
-					return fmt.Errorf("[HTTP %d] Error creating plugin for route %s. Bad response from Kong API", res.StatusCode, route)
-				}
+	for _, collection := range collections {
+		entities, err := c.getAll(collection.path)
 
-				fmt.Printf("[HTTP %d] Plugin created for route %s \n", res.StatusCode, route)
-			}
+		if err != nil {
+			return nil, err
+		}
+
+		*collection.entities = entities
+	}
+
+	index := newLiveIndex(s)
+	plugins := make(map[string]Entity)
+
+	for _, e := range s.Plugins {
+		// Plugins applied to consumers are not managed by kongfig
+		if foreignID(e, "consumer") != "" {
+			continue
+		}
+
+		plugins[index.pluginScope(e)] = e
+	}
+
+	return plugins, nil
+}
+
+// DeleteStalePlugins deletes the plugins of Kong missing from the config,
+// leaving the ones applied to consumers untouched
+func (c *Client) DeleteStalePlugins() error {
+	live, err := c.livePlugins()
+
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]bool)
+
+	for _, p := range desiredPlugins(c.config) {
+		desired[p.name] = true
+	}
+
+	scopes := []string{}
+
+	for scope := range live {
+		if !desired[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	sort.Strings(scopes)
+
+	for _, scope := range scopes {
+		if err := c.DeletePlugin(Plugin{ID: live[scope].ID(), Name: scope}); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// createPlugin creates a plugin on the collection at url, reporting it under name
+func (c *Client) createPlugin(plugin Plugin, url, name string) error {
+	return c.track(KindPlugin, name, ActionCreate, func() (int, error) {
+		payload, err := json.Marshal(plugin)
+
+		if err != nil {
+			c.Logger.Errorf("Error marshalling payload for plugin %s: %s", name, err)
+			return 0, err
+		}
+
+		res, err := c.httpRequest(http.MethodPost, url, payload, nil)
+
+		if err != nil {
+			c.Logger.Errorf("Error creating plugin %s: %s", name, err)
+			return 0, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating plugin %s. Not found", res.StatusCode, name)
+		}
+
+		if res.StatusCode != http.StatusCreated {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating plugin %s. Bad response from Kong API", res.StatusCode, name)
+		}
+
+		return res.StatusCode, nil
+	})
+}
+
 // GetPlugins fetches all plugins from Kong
 func (c *Client) GetPlugins() ([]Plugin, error) {
 	url := fmt.Sprintf("%s/plugins", c.BaseURL)
@@ -531,45 +833,58 @@ func (c *Client) DeletePlugins() error {
 
 // DeletePlugin deletes a plugin for a service based on route id
 func (c *Client) DeletePlugin(plugin Plugin) error {
-	url := fmt.Sprintf("%s/plugins/%s", c.BaseURL, plugin.ID)
-	res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
+	return c.track(KindPlugin, plugin.Name, ActionDelete, func() (int, error) {
+		url := fmt.Sprintf("%s/plugins/%s", c.BaseURL, plugin.ID)
+		res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
 
-	if err != nil {
-		return err
-	}
-
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("[HTTP %d] Error deleting plugin. Bad response response from the API", res.StatusCode)
-	}
+		if err != nil {
+			return 0, err
+		}
 
-	fmt.Printf("[HTTP %d] Plugin [%s] deleted \n", res.StatusCode, plugin.Name)
+		if res.StatusCode != http.StatusNoContent {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error deleting plugin. Bad response response from the API", res.StatusCode)
+		}
 
-	return nil
+		return res.StatusCode, nil
+	})
 }
 
+// CreateCredentials iterates through all credentials and creates them for their consumer
 func (c *Client) CreateCredentials() error {
 	for _, r := range c.config.Credentials {
-		url := fmt.Sprintf("%s/consumers/%s/%s", c.BaseURL, r.Target, r.Name)
+		name := credentialName(r.Name, r.Target, r.Config[credentialKeys[r.Name]])
 
-		payload, err := json.Marshal(r.Config)
+		err := c.track(KindCredential, name, ActionCreate, func() (int, error) {
+			url := fmt.Sprintf("%s/consumers/%s/%s", c.BaseURL, r.Target, r.Name)
 
-		if err != nil {
-			return err
-		}
+			payload, err := json.Marshal(r.Config)
 
-		cred := Credential{}
-		res, err := c.httpRequest(http.MethodPost, url, payload, &cred)		
+			if err != nil {
+				return 0, err
+			}
 
-		if res.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("[HTTP %d] Error creating credential: Target not found", res.StatusCode)
-		}
+			cred := Credential{}
+			res, err := c.httpRequest(http.MethodPost, url, payload, &cred)
 
-		if res.StatusCode != http.StatusCreated {
-			return fmt.Errorf("[HTTP %d] Error creating credential. Bad response from Kong API", res.StatusCode)
-		}
+			if err != nil {
+				return 0, err
+			}
+
+			if res.StatusCode == http.StatusNotFound {
+				return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating credential: Target not found", res.StatusCode)
+			}
 
-		fmt.Printf("[HTTP %d] Credential created for Consumer %s \n", res.StatusCode, r.Target)
+			if res.StatusCode != http.StatusCreated {
+				return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating credential. Bad response from Kong API", res.StatusCode)
+			}
+
+			return res.StatusCode, nil
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,143 @@
+package api
+
+//go:generate go run gen_schemas.go
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// enabledPlugins represents the response body of GET /plugins/enabled
+type enabledPlugins struct {
+	EnabledPlugins []string `json:"enabled_plugins"`
+}
+
+// bundledSchemas holds the schemas of a Kong version, keyed by kind and name
+type bundledSchemas map[string]string
+
+func (b bundledSchemas) Schema(kind, name string) ([]byte, error) {
+	if schema, ok := b[kind+"/"+name]; ok {
+		return []byte(schema), nil
+	}
+
+	return nil, nil
+}
+
+// BundledSchemas returns the schemas of core plugins and credentials shipped
+// with kongfig for a Kong version, matched by major and minor version. It
+// returns nil when kongfig has no schemas for the version
+func BundledSchemas(version string) SchemaSource {
+	if schemas, ok := bundled[schemaVersion(version)]; ok {
+		return schemas
+	}
+
+	return nil
+}
+
+// schemaVersion returns the major and minor parts of a Kong version, as
+// schemas don't change between patch releases
+func schemaVersion(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+
+	if len(parts) < 2 {
+		return version
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// SchemaVersionDir returns the directory of dir holding the schemas of a Kong
+// version, shared by the versions with the same major and minor parts
+func SchemaVersionDir(dir, version string) string {
+	return filepath.Join(dir, schemaVersion(version))
+}
+
+// chainedSchemas returns the first schema found in a list of sources
+type chainedSchemas []SchemaSource
+
+func (sources chainedSchemas) Schema(kind, name string) ([]byte, error) {
+	for _, s := range sources {
+		data, err := s.Schema(kind, name)
+
+		if err != nil || data != nil {
+			return data, err
+		}
+	}
+
+	return nil, nil
+}
+
+// ChainSchemas returns a source looking up schemas in every source in order,
+// nil sources are skipped
+func ChainSchemas(sources ...SchemaSource) SchemaSource {
+	chain := chainedSchemas{}
+
+	for _, s := range sources {
+		if s != nil {
+			chain = append(chain, s)
+		}
+	}
+
+	return chain
+}
+
+// RefreshSchemas stores the schemas of every plugin enabled in Kong, and of
+// credentials, in a directory of dir named after the Kong major and minor
+// version, replacing the schemas stored for that version. It returns that directory
+func (c *Client) RefreshSchemas(dir string) (string, error) {
+	version, err := c.KongVersion()
+
+	if err != nil {
+		return "", err
+	}
+
+	enabled := enabledPlugins{}
+	res, err := c.httpRequest(http.MethodGet, c.BaseURL+"/plugins/enabled", nil, &enabled)
+
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("[HTTP %d] Error fetching enabled plugins. Bad response from the API", res.StatusCode)
+	}
+
+	target := SchemaVersionDir(dir, version)
+
+	if err := os.RemoveAll(target); err != nil {
+		return "", err
+	}
+
+	cache := &SchemaCache{Dir: target, Source: &liveSchemas{c: c}}
+	fetch := func(kind, name string) error {
+		_, err := cache.Schema(kind, name)
+
+		if err == errNotFound {
+			c.Logger.Infof("Kong %s has no schema for %s %s, skipping", version, kind, name)
+			return nil
+		}
+
+		if err == nil {
+			c.Logger.Debugf("Stored schema of %s %s", kind, name)
+		}
+
+		return err
+	}
+
+	for _, name := range enabled.EnabledPlugins {
+		if err := fetch(KindPlugin, name); err != nil {
+			return "", err
+		}
+	}
+
+	for _, name := range sortedCredentialTypes() {
+		if err := fetch(KindCredential, name); err != nil {
+			return "", err
+		}
+	}
+
+	return target, nil
+}
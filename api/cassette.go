@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+const cassetteFile = "cassette.json"
+
+// Interaction is a request made to the Admin API along with the response of Kong
+type Interaction struct {
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// Cassette holds every interaction of a kongfig run with the Admin API, in order
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+
+	mu sync.Mutex
+}
+
+// Save writes the cassette to dir and returns its path. Cassettes contain
+// the credentials returned by Kong, they are only readable by their owner
+func (c *Cassette) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, cassetteFile)
+
+	return path, ioutil.WriteFile(path, data, 0600)
+}
+
+// LoadCassette reads the cassette previously saved to dir
+func LoadCassette(dir string) (*Cassette, error) {
+	path := filepath.Join(dir, cassetteFile)
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("Error parsing cassette %s: %s", path, err)
+	}
+
+	return c, nil
+}
+
+func (c *Cassette) add(i Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, i)
+}
+
+// recordingTransport appends every request made through base and its response to a cassette
+type recordingTransport struct {
+	base     http.RoundTripper
+	cassette *Cassette
+}
+
+// NewRecordingTransport returns a RoundTripper recording every request made through base into cassette
+func NewRecordingTransport(base http.RoundTripper, cassette *Cassette) http.RoundTripper {
+	return &recordingTransport{base: base, cassette: cassette}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody := readRequestBody(req)
+	res, err := t.base.RoundTrip(req)
+
+	if err != nil {
+		return res, err
+	}
+
+	resBody, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	t.cassette.add(Interaction{
+		Method:   req.Method,
+		Path:     req.URL.RequestURI(),
+		Request:  rawJSON(reqBody),
+		Status:   res.StatusCode,
+		Response: rawJSON(resBody),
+	})
+
+	return res, nil
+}
+
+// ReplayTransport serves the interactions of a cassette in order instead of
+// reaching Kong. The first request differing from the recorded one stops the
+// replay, every later request fails
+type ReplayTransport struct {
+	cassette *Cassette
+	next     int
+	err      error
+	mu       sync.Mutex
+}
+
+// NewReplayTransport returns a RoundTripper replaying cassette
+func NewReplayTransport(cassette *Cassette) *ReplayTransport {
+	return &ReplayTransport{cassette: cassette}
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	reqBody := readRequestBody(req)
+	n := t.next + 1
+
+	if t.next >= len(t.cassette.Interactions) {
+		t.err = fmt.Errorf("Request %d %s %s is missing from the cassette, which has %d request(s)", n, req.Method, req.URL.RequestURI(), len(t.cassette.Interactions))
+		return nil, t.err
+	}
+
+	i := t.cassette.Interactions[t.next]
+
+	if i.Method != req.Method || i.Path != req.URL.RequestURI() {
+		t.err = fmt.Errorf("Request %d does not match the cassette: expected %s %s, got %s %s", n, i.Method, i.Path, req.Method, req.URL.RequestURI())
+		return nil, t.err
+	}
+
+	if !equalJSON(i.Request, rawJSON(reqBody)) {
+		t.err = fmt.Errorf("Request %d %s %s does not match the cassette: expected body %s, got %s", n, req.Method, i.Path, compactJSON(i.Request), compactJSON(rawJSON(reqBody)))
+		return nil, t.err
+	}
+
+	t.next++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{contentType: []string{applicationJSON}},
+		Body:          ioutil.NopCloser(bytes.NewReader(i.Response)),
+		ContentLength: int64(len(i.Response)),
+		Request:       req,
+	}, nil
+}
+
+// Verify returns the first request that didn't match the cassette, or an
+// error when recorded requests were not made
+func (t *ReplayTransport) Verify() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+
+	if left := len(t.cassette.Interactions) - t.next; left > 0 {
+		i := t.cassette.Interactions[t.next]
+		return fmt.Errorf("%d recorded request(s) were not made, starting with request %d %s %s", left, t.next+1, i.Method, i.Path)
+	}
+
+	return nil
+}
+
+// readRequestBody returns the body of req, leaving it readable by the transport
+func readRequestBody(req *http.Request) []byte {
+	if req.Body == nil {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body
+}
+
+// rawJSON keeps a body as is when it is JSON, or as a JSON string otherwise
+func rawJSON(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	if json.Valid(body) {
+		return json.RawMessage(bytes.TrimSpace(body))
+	}
+
+	data, _ := json.Marshal(string(body))
+
+	return data
+}
+
+// compactJSON returns a JSON document on a single line
+func compactJSON(data json.RawMessage) string {
+	buf := &bytes.Buffer{}
+
+	if json.Compact(buf, data) != nil {
+		return string(data)
+	}
+
+	return buf.String()
+}
+
+// equalJSON compares two JSON documents regardless of attribute order and formatting
+func equalJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+
+	var va, vb interface{}
+
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Cluster is one of the Kong instances a config is applied to
+type Cluster struct {
+	Name  string `yaml:"name"`
+	Host  string `yaml:"host"`
+	HTTPS bool   `yaml:"https,omitempty"`
+	// Headers are sent with every Admin API request to the cluster, on top of
+	// the headers of the config, eg: Kong-Admin-Token
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// checkClusters fails when the clusters of a config are missing a name or a
+// host, or have the same name
+func checkClusters(c *Config) error {
+	if len(c.Clusters) == 0 {
+		return nil
+	}
+
+	if c.Host != "" {
+		return fmt.Errorf("The config sets both host and clusters, move host to a cluster")
+	}
+
+	names := make(map[string]bool)
+
+	for i, cluster := range c.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("Cluster %d of the config has no name", i+1)
+		}
+
+		if cluster.Host == "" {
+			return fmt.Errorf("Cluster %s of the config has no host", cluster.Name)
+		}
+
+		if names[cluster.Name] {
+			return fmt.Errorf("Cluster %s is defined more than once in the config", cluster.Name)
+		}
+
+		names[cluster.Name] = true
+	}
+
+	return nil
+}
+
+// ForCluster returns the config targeting the host of one of its clusters
+func (c *Config) ForCluster(name string) (*Config, error) {
+	for _, cluster := range c.Clusters {
+		if cluster.Name != name {
+			continue
+		}
+
+		config := *c
+		config.Host = cluster.Host
+		config.HTTPS = cluster.HTTPS
+		config.Clusters = nil
+		config.Headers = make(map[string]string)
+
+		for k, v := range c.Headers {
+			config.Headers[k] = v
+		}
+
+		for k, v := range cluster.Headers {
+			config.Headers[k] = v
+		}
+
+		return &config, nil
+	}
+
+	names := []string{}
+
+	for _, cluster := range c.Clusters {
+		names = append(names, cluster.Name)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Unknown cluster %s, the config has no clusters", name)
+	}
+
+	return nil, fmt.Errorf("Unknown cluster %s, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// NewClusterClients returns a client for each named cluster of a config, for
+// every cluster when names is empty. A config without clusters gets a single
+// client for its host
+func NewClusterClients(config *Config, names []string) ([]*Client, error) {
+	if len(config.Clusters) == 0 && len(names) == 0 {
+		return []*Client{NewConfigClient(config)}, nil
+	}
+
+	if len(names) == 0 {
+		for _, cluster := range config.Clusters {
+			names = append(names, cluster.Name)
+		}
+	}
+
+	clients := []*Client{}
+
+	for _, name := range names {
+		c, err := config.ForCluster(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		client := NewConfigClient(c)
+		client.Cluster = name
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// FanOut runs a command against several clusters
+type FanOut struct {
+	// Parallel runs the command against every cluster at once instead of one after the other
+	Parallel bool
+	// Canary stops at the first cluster the command fails on, skipping the
+	// remaining ones. In parallel, the first cluster goes alone before the others
+	Canary bool
+}
+
+// ClusterResult is the outcome of a command run against a cluster
+type ClusterResult struct {
+	Cluster  string        `json:"cluster"`
+	Host     string        `json:"host"`
+	Status   string        `json:"status"`
+	Result   string        `json:"result,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// Run runs fn against every client, returning their results in the same order.
+// fn returns a short description of its result, eg: in sync
+func (f *FanOut) Run(clients []*Client, fn func(c *Client) (string, error)) []ClusterResult {
+	results := make([]ClusterResult, len(clients))
+	run := func(i int) bool {
+		c := clients[i]
+		start := time.Now()
+		result, err := fn(c)
+		results[i] = ClusterResult{Cluster: c.Cluster, Host: c.BaseURL, Status: StatusOK, Result: result, Duration: time.Since(start)}
+
+		if err != nil {
+			results[i].Status = StatusFailed
+			results[i].Error = err.Error()
+		}
+
+		return err == nil
+	}
+
+	for i, c := range clients {
+		results[i] = ClusterResult{Cluster: c.Cluster, Host: c.BaseURL, Status: StatusSkipped}
+	}
+
+	if !f.Parallel {
+		for i := range clients {
+			if !run(i) && f.Canary {
+				break
+			}
+		}
+
+		return results
+	}
+
+	first := 0
+
+	if f.Canary && len(clients) > 0 {
+		if !run(0) {
+			return results
+		}
+
+		first = 1
+	}
+
+	var wg sync.WaitGroup
+
+	for i := first; i < len(clients); i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// WriteClusterSummary writes a table with the result of a command on every cluster
+func WriteClusterSummary(w io.Writer, results []ClusterResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tHOST\tSTATUS\tDURATION\tRESULT")
+
+	for _, r := range results {
+		result := r.Result
+
+		if r.Error != "" {
+			result = r.Error
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Cluster, r.Host, r.Status, r.Duration.Round(time.Millisecond), result)
+	}
+
+	tw.Flush()
+}
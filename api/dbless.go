@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Entity types and actions reported in events of DB-less mode
+const (
+	KindConfig string = "config"
+	ActionLoad string = "load"
+)
+
+// nodeInfo represents the response body of GET /, the Kong node information
+type nodeInfo struct {
+	Version       string `json:"version"`
+	Configuration struct {
+		Database string `json:"database"`
+	} `json:"configuration"`
+}
+
+// configResponse represents the response body of POST /config
+type configResponse struct {
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields"`
+}
+
+// statusResponse represents the response body of GET /status
+type statusResponse struct {
+	ConfigurationHash string `json:"configuration_hash"`
+}
+
+// DBLess reports whether Kong runs without a database, in which case the
+// per-entity endpoints of the Admin API are read-only
+func (c *Client) DBLess() (bool, error) {
+	info, err := c.nodeInfo()
+
+	if err != nil {
+		return false, err
+	}
+
+	return info.Configuration.Database == "off", nil
+}
+
+// KongVersion returns the version of the Kong node
+func (c *Client) KongVersion() (string, error) {
+	info, err := c.nodeInfo()
+
+	if err != nil {
+		return "", err
+	}
+
+	return info.Version, nil
+}
+
+func (c *Client) nodeInfo() (*nodeInfo, error) {
+	info := &nodeInfo{}
+	res, err := c.httpRequest(http.MethodGet, c.rootURL()+"/", nil, info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[HTTP %d] Error fetching node information. Bad response from the API", res.StatusCode)
+	}
+
+	return info, nil
+}
+
+// ApplyDeclarative renders the config into Kong's declarative format and
+// loads it at once through POST /config. Kong either loads the whole config
+// or rejects it, so no snapshot is needed
+func (c *Client) ApplyDeclarative() error {
+	d, warnings, err := ToDeclarative(c.config)
+
+	if err != nil {
+		return err
+	}
+
+	for _, w := range warnings {
+		c.Logger.Debugf("%s", w)
+	}
+
+	rendered, err := json.Marshal(d)
+
+	if err != nil {
+		return err
+	}
+
+	return c.track(KindConfig, c.BaseURL, ActionLoad, func() (int, error) {
+		payload, err := json.Marshal(map[string]string{"config": string(rendered)})
+
+		if err != nil {
+			return 0, err
+		}
+
+		body := configResponse{}
+		res, err := c.httpRequest(http.MethodPost, c.rootURL()+"/config", payload, &body)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode == http.StatusBadRequest {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Kong rejected the declarative config: %s%s", res.StatusCode, body.Message, schemaErrors(body.Fields))
+		}
+
+		if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error loading declarative config. Bad response from the API", res.StatusCode)
+		}
+
+		c.Logger.Infof("Declarative config loaded, configuration hash: %s", c.configurationHash(rendered))
+
+		return res.StatusCode, nil
+	})
+}
+
+// configurationHash returns the hash Kong reports for the loaded configuration.
+// Older versions don't report it, the MD5 of the rendered config is used instead
+func (c *Client) configurationHash(rendered []byte) string {
+	status := statusResponse{}
+	res, err := c.httpRequest(http.MethodGet, c.rootURL()+"/status", nil, &status)
+
+	if err == nil && res.StatusCode == http.StatusOK && status.ConfigurationHash != "" {
+		return status.ConfigurationHash
+	}
+
+	sum := md5.Sum(rendered)
+
+	return hex.EncodeToString(sum[:]) + " (computed locally)"
+}
+
+// schemaErrors flattens the nested field errors returned by Kong into one line per field
+func schemaErrors(fields map[string]interface{}) string {
+	lines := []string{}
+	flattenErrors("", fields, &lines)
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "\n  " + strings.Join(lines, "\n  ")
+}
+
+func flattenErrors(prefix string, v interface{}, lines *[]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, nested := range value {
+			flattenErrors(joinPath(prefix, k), nested, lines)
+		}
+	case []interface{}:
+		for i, nested := range value {
+			flattenErrors(joinPath(prefix, fmt.Sprint(i)), nested, lines)
+		}
+	default:
+		*lines = append(*lines, fmt.Sprintf("%s: %v", prefix, value))
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	yaml "gopkg.in/mikefarah/yaml.v2"
+)
+
+const declarativeFormatVersion = "1.1"
+
+var (
+	// Maps credential plugin names, as used in the config file, to the
+	// attribute nesting them under consumers in the declarative format
+	declarativeCredentials = map[string]string{
+		"acls":       "acls",
+		"basic-auth": "basicauth_credentials",
+		"hmac-auth":  "hmacauth_credentials",
+		"jwt":        "jwt_secrets",
+		"key-auth":   "keyauth_credentials",
+		"oauth2":     "oauth2_credentials",
+	}
+
+	// Top-level attributes of the declarative format kongfig understands
+	declarativeSections = map[string]bool{
+		"_format_version": true,
+		"services":        true,
+		"routes":          true,
+		"plugins":         true,
+		"consumers":       true,
+	}
+)
+
+// Declarative models Kong's declarative configuration format, where routes
+// and plugins are nested under the entities they belong to
+type Declarative struct {
+	FormatVersion string   `yaml:"_format_version" json:"_format_version"`
+	Services      []Entity `yaml:"services,omitempty" json:"services,omitempty"`
+	Plugins       []Entity `yaml:"plugins,omitempty" json:"plugins,omitempty"`
+	Consumers     []Entity `yaml:"consumers,omitempty" json:"consumers,omitempty"`
+}
+
+// WriteDeclarative writes a declarative config as YAML, or JSON for the json output format
+func WriteDeclarative(w io.Writer, format string, d *Declarative) error {
+	switch format {
+	case OutputText:
+		data, err := yaml.Marshal(d)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(data)
+
+		return err
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(d)
+	}
+
+	return fmt.Errorf("Output format %s is not supported when converting a config", format)
+}
+
+// ToDeclarative renders a config into Kong's declarative format. It also
+// returns a warning for every part of the config that can't be represented
+func ToDeclarative(config *Config) (*Declarative, []string, error) {
+	d := &Declarative{FormatVersion: declarativeFormatVersion}
+	warnings := []string{}
+
+	if config.Host != "" || config.HTTPS {
+		warnings = append(warnings, "host and https are connection settings, they are not part of the declarative format")
+	}
+
+	services := make(map[string]Entity)
+	routes := make(map[string]Entity)
+
+	for _, s := range config.Services {
+		e := toEntity(s)
+		services[s.Name] = e
+		d.Services = append(d.Services, e)
+	}
+
+	for _, r := range config.Routes {
+		service, ok := services[r.Service]
+
+		if !ok {
+			return nil, warnings, fmt.Errorf("Route %s applies to unknown service %s", r.Name, r.Service)
+		}
+
+		e := toEntity(r)
+		delete(e, "service")
+		delete(e, "id")
+
+		// Plugins reference routes by name, unnamed routes can't have any
+		if r.Name != "" {
+			if _, ok := routes[r.Name]; ok {
+				return nil, warnings, fmt.Errorf("Route %s is defined more than once in the config", r.Name)
+			}
+
+			routes[r.Name] = e
+		}
+
+		appendNested(service, "routes", e)
+	}
+
+	for _, p := range config.Plugins {
+		e := toEntity(p)
+		delete(e, "id")
+
+		if p.Target == "global" {
+			d.Plugins = append(d.Plugins, e)
+			continue
+		}
+
+		if len(p.Services) == 0 && len(p.Routes) == 0 {
+			warnings = append(warnings, fmt.Sprintf("plugin %s applies to no service or route and was dropped", p.Name))
+		}
+
+		for _, name := range p.Services {
+			service, ok := services[name]
+
+			if !ok {
+				return nil, warnings, fmt.Errorf("Plugin %s applies to unknown service %s", p.Name, name)
+			}
+
+			appendNested(service, "plugins", copyEntity(e))
+		}
+
+		for _, name := range p.Routes {
+			route, ok := routes[name]
+
+			if !ok {
+				return nil, warnings, fmt.Errorf("Plugin %s applies to unknown route %s", p.Name, name)
+			}
+
+			appendNested(route, "plugins", copyEntity(e))
+		}
+	}
+
+	consumers := make(map[string]Entity)
+
+	for _, c := range config.Consumers {
+		e := toEntity(c)
+		consumers[c.Username] = e
+		d.Consumers = append(d.Consumers, e)
+	}
+
+	for _, c := range config.Credentials {
+		consumer, ok := consumers[c.Target]
+
+		if !ok {
+			return nil, warnings, fmt.Errorf("Credential %s targets unknown consumer %s", c.Name, c.Target)
+		}
+
+		attribute, ok := declarativeCredentials[c.Name]
+
+		if !ok {
+			return nil, warnings, fmt.Errorf("Credential type %s is not supported", c.Name)
+		}
+
+		appendNested(consumer, attribute, toEntity(c.Config))
+	}
+
+	return d, warnings, nil
+}
+
+// FromDeclarative converts a file in Kong's declarative format into a config.
+// It also returns a warning for every entity or attribute kongfig can't represent,
+// which is left out of the config
+func FromDeclarative(data []byte) (*Config, []string, error) {
+	raw := map[string]interface{}{}
+
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := raw["_format_version"]; !ok {
+		return nil, nil, fmt.Errorf("Not a declarative config: _format_version is missing")
+	}
+
+	f := &fromDeclarative{config: &Config{}, warnings: []string{}, routeNames: make(map[string]string)}
+
+	for _, section := range sortedAttributes(raw) {
+		if !declarativeSections[section] {
+			f.warn("%s are not supported by kongfig and were dropped", section)
+		}
+	}
+
+	for _, s := range entityList(raw["services"]) {
+		f.service(s)
+	}
+
+	for _, r := range entityList(raw["routes"]) {
+		service := r["service"]
+
+		if ref, ok := service.(map[string]interface{}); ok {
+			service = ref["name"]
+		}
+
+		name, ok := service.(string)
+
+		if !ok {
+			f.warn("route %v has no service and was dropped", r["name"])
+			continue
+		}
+
+		f.route(name, r)
+	}
+
+	for _, p := range entityList(raw["plugins"]) {
+		f.plugin(p, "", "")
+	}
+
+	for _, c := range entityList(raw["consumers"]) {
+		f.consumer(c)
+	}
+
+	return f.config, f.warnings, nil
+}
+
+// fromDeclarative keeps track of the conversion of a declarative config
+type fromDeclarative struct {
+	config   *Config
+	warnings []string
+	// Maps route IDs to names, for plugins referencing routes by ID
+	routeNames map[string]string
+}
+
+func (f *fromDeclarative) warn(format string, args ...interface{}) {
+	f.warnings = append(f.warnings, fmt.Sprintf(format, args...))
+}
+
+// unsupported warns about the attributes of e kongfig drops, besides the given nested ones
+func (f *fromDeclarative) unsupported(kind, name string, e Entity, v interface{}, nested ...string) {
+	known := make(map[string]bool)
+
+	for _, field := range managedFields(v) {
+		known[field] = true
+	}
+
+	for _, field := range nested {
+		known[field] = true
+	}
+
+	for _, attribute := range sortedAttributes(e) {
+		if !known[attribute] {
+			f.warn("%s %s: attribute %s is not supported by kongfig and was dropped", kind, name, attribute)
+		}
+	}
+}
+
+func (f *fromDeclarative) service(e Entity) {
+	s := Service{}
+	fromEntity(e, &s)
+	f.unsupported(KindService, s.Name, e, s, "routes", "plugins")
+	f.config.Services = append(f.config.Services, s)
+
+	for _, r := range entityList(e["routes"]) {
+		f.route(s.Name, r)
+	}
+
+	for _, p := range entityList(e["plugins"]) {
+		f.plugin(p, s.Name, "")
+	}
+}
+
+func (f *fromDeclarative) route(service string, e Entity) {
+	r := Route{}
+	fromEntity(e, &r)
+	r.Service = service
+
+	// Kong strips paths by default, kongfig sends false when omitted
+	if _, ok := e["strip_path"]; !ok {
+		r.StripPath = true
+	}
+
+	f.unsupported(KindRoute, r.Name, e, r, "plugins")
+
+	if r.Name == "" {
+		f.warn("route of service %s with paths %v has no name, which kongfig requires to detect drift", service, r.Paths)
+	}
+
+	if id := stringField(e, "id"); id != "" {
+		f.routeNames[id] = r.Name
+	}
+
+	f.config.Routes = append(f.config.Routes, r)
+
+	for _, p := range entityList(e["plugins"]) {
+		f.plugin(p, "", r.Name)
+	}
+}
+
+func (f *fromDeclarative) plugin(e Entity, service, route string) {
+	p := Plugin{}
+	fromEntity(e, &p)
+
+	if e["consumer"] != nil {
+		f.warn("plugin %s applies to a consumer, which kongfig doesn't support, and was dropped", p.Name)
+		return
+	}
+
+	if service == "" {
+		service = referenceName(e["service"], nil)
+	}
+
+	if route == "" {
+		route = referenceName(e["route"], f.routeNames)
+	}
+
+	switch {
+	case service != "":
+		p.Services = []string{service}
+	case route != "":
+		p.Routes = []string{route}
+	default:
+		p.Target = "global"
+	}
+
+	f.unsupported(KindPlugin, p.Name, e, p, "service", "route")
+	f.config.Plugins = append(f.config.Plugins, p)
+}
+
+func (f *fromDeclarative) consumer(e Entity) {
+	c := Consumer{}
+	fromEntity(e, &c)
+
+	nested := []string{"plugins"}
+
+	for _, name := range sortedCredentialTypes() {
+		attribute := declarativeCredentials[name]
+		nested = append(nested, attribute)
+
+		for _, cred := range entityList(e[attribute]) {
+			f.config.Credentials = append(f.config.Credentials, Credential{Name: name, Target: c.Username, Config: cred})
+		}
+	}
+
+	if len(entityList(e["plugins"])) > 0 {
+		f.warn("consumer %s: plugins applied to consumers are not supported by kongfig and were dropped", c.Username)
+	}
+
+	f.unsupported(KindConsumer, c.Username, e, c, nested...)
+	f.config.Consumers = append(f.config.Consumers, c)
+}
+
+// referenceName returns the name of the entity a foreign key references,
+// which is either a name or an ID in the declarative format
+func referenceName(ref interface{}, namesByID map[string]string) string {
+	if m, ok := ref.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok {
+			return name
+		}
+
+		ref = m["id"]
+	}
+
+	name, _ := ref.(string)
+
+	if byID, ok := namesByID[name]; ok {
+		return byID
+	}
+
+	return name
+}
+
+// fromEntity decodes the attributes of an entity into a config struct
+func fromEntity(e Entity, v interface{}) {
+	data, _ := json.Marshal(e)
+	json.Unmarshal(data, v)
+}
+
+// entityList converts a decoded list of objects into entities
+func entityList(v interface{}) []Entity {
+	entities := []Entity{}
+	list, _ := v.([]interface{})
+
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			entities = append(entities, Entity(m))
+		}
+	}
+
+	return entities
+}
+
+func sortedAttributes(m map[string]interface{}) []string {
+	keys := []string{}
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedCredentialTypes() []string {
+	types := []string{}
+
+	for t := range declarativeCredentials {
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// appendNested adds child to the list of nested entities under attribute
+func appendNested(parent Entity, attribute string, child Entity) {
+	children, _ := parent[attribute].([]Entity)
+	parent[attribute] = append(children, child)
+}
+
+func copyEntity(e Entity) Entity {
+	c := Entity{}
+
+	for k, v := range e {
+		c[k] = v
+	}
+
+	return c
+}
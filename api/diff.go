@@ -0,0 +1,683 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of entities managed by kongfig, in the order changes are reported
+const (
+	KindService    string = "service"
+	KindRoute      string = "route"
+	KindConsumer   string = "consumer"
+	KindCredential string = "credential"
+	KindPlugin     string = "plugin"
+)
+
+// Actions needed to bring Kong in sync with the config
+const (
+	ActionCreate string = "create"
+	ActionUpdate string = "update"
+	ActionDelete string = "delete"
+	// Enabling or disabling an entity is reported apart from other updates
+	ActionEnable  string = "enable"
+	ActionDisable string = "disable"
+)
+
+var (
+	kindOrder = map[string]int{
+		KindService:    0,
+		KindRoute:      1,
+		KindConsumer:   2,
+		KindCredential: 3,
+		KindPlugin:     4,
+	}
+
+	// Values Kong assigns to attributes omitted from the config
+	serviceDefaults = Entity{
+		"protocol":        "http",
+		"port":            float64(80),
+		"connect_timeout": float64(60000),
+		"write_timeout":   float64(60000),
+		"read_timeout":    float64(60000),
+		"retries":         float64(5),
+	}
+
+	routeDefaults = Entity{
+		"protocols":      []interface{}{"http", "https"},
+		"regex_priority": float64(0),
+	}
+
+	// Attribute identifying a credential of a given type for a consumer
+	credentialKeys = map[string]string{
+		"acls":       "group",
+		"basic-auth": "username",
+		"hmac-auth":  "username",
+		"jwt":        "key",
+		"key-auth":   "key",
+		"oauth2":     "client_id",
+	}
+
+	// Credential attributes Kong stores hashed, which can never be compared
+	credentialHashed = map[string][]string{
+		"basic-auth": {"password"},
+	}
+)
+
+// FieldDiff represents a single attribute that differs between the config and Kong
+type FieldDiff struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Change represents an entity that must be created, updated or deleted
+type Change struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Action string      `json:"action"`
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// Diff represents every difference between the config and the live state of Kong
+type Diff struct {
+	Changes []Change `json:"changes"`
+
+	// Entities matching the config, only reported as events
+	unchanged []Change
+}
+
+// Events returns an event for every entity compared, reporting drift as a
+// distinct status
+func (d *Diff) Events() []Event {
+	events := []Event{}
+
+	for _, change := range d.unchanged {
+		events = append(events, Event{Entity: change.Kind, Name: change.Name, Action: ActionNone, Status: StatusOK})
+	}
+
+	for _, change := range d.Changes {
+		events = append(events, Event{Entity: change.Kind, Name: change.Name, Action: change.Action, Status: StatusDrift, Fields: change.Fields})
+	}
+
+	return events
+}
+
+// InSync reports whether Kong matches the config
+func (d *Diff) InSync() bool {
+	return len(d.Changes) == 0
+}
+
+// WriteText writes a human readable diff, one line per changed entity
+func (d *Diff) WriteText(w io.Writer) {
+	if d.InSync() {
+		fmt.Fprintln(w, "No differences found, Kong is in sync with the config")
+		return
+	}
+
+	symbols := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-", ActionEnable: "~", ActionDisable: "~"}
+
+	for _, change := range d.Changes {
+		fmt.Fprintf(w, "%s %s %s", symbols[change.Action], change.Kind, change.Name)
+
+		if change.Action == ActionEnable || change.Action == ActionDisable {
+			fmt.Fprintf(w, " (%s)", change.Action)
+		}
+
+		fmt.Fprintln(w)
+
+		for _, f := range change.Fields {
+			if change.Action == ActionCreate {
+				fmt.Fprintf(w, "    %s: %s\n", f.Field, jsonString(f.New))
+				continue
+			}
+
+			fmt.Fprintf(w, "    %s: %s => %s\n", f.Field, jsonString(f.Old), jsonString(f.New))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d change(s) needed to bring Kong in sync with the config\n", len(d.Changes))
+}
+
+// WriteJSON writes the diff as a JSON report
+func (d *Diff) WriteJSON(w io.Writer) error {
+	report := struct {
+		InSync  bool     `json:"in_sync"`
+		Changes []Change `json:"changes"`
+	}{d.InSync(), d.Changes}
+
+	if report.Changes == nil {
+		report.Changes = []Change{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(report)
+}
+
+// Diff compares the config against the live state of Kong, restricted to the
+// entities of Selector
+func (c *Client) Diff() (*Diff, error) {
+	snapshot, err := c.TakeSnapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	diff := ComputeDiff(c.Selector.Select(c.config, snapshot))
+	c.Logger.Debugf("%d change(s) found between the config and %s", len(diff.Changes), c.BaseURL)
+
+	return diff, nil
+}
+
+// ComputeDiff compares a config against a snapshot of Kong. Entities are
+// matched by name; only the attributes kongfig manages are compared
+func ComputeDiff(config *Config, s *Snapshot) *Diff {
+	live := newLiveIndex(s)
+	d := &Diff{}
+
+	d.compare(KindService, desiredServices(config), live.services())
+	d.compare(KindRoute, desiredRoutes(config), live.routes())
+	d.compare(KindConsumer, desiredConsumers(config), live.consumers())
+	d.compare(KindCredential, desiredCredentials(config), live.credentials())
+	d.compare(KindPlugin, desiredPlugins(config), live.plugins())
+
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		if d.Changes[i].Kind != d.Changes[j].Kind {
+			return kindOrder[d.Changes[i].Kind] < kindOrder[d.Changes[j].Kind]
+		}
+
+		return d.Changes[i].Name < d.Changes[j].Name
+	})
+
+	return d
+}
+
+// keyedEntity is an entity reduced to the attributes kongfig manages, keyed by
+// the name used to match the config against Kong
+type keyedEntity struct {
+	name     string
+	fields   Entity
+	defaults Entity
+	// Only compare attributes present in the config, eg: plugin config
+	subset bool
+	// detailed lists the attributes of the entity when it is created
+	detailed bool
+}
+
+// compare records the changes needed to turn the live entities into the desired ones
+func (d *Diff) compare(kind string, desired, live []keyedEntity) {
+	liveByName := make(map[string]keyedEntity)
+
+	for _, l := range live {
+		liveByName[l.name] = l
+	}
+
+	seen := make(map[string]bool)
+
+	for _, want := range desired {
+		seen[want.name] = true
+		have, ok := liveByName[want.name]
+
+		if !ok {
+			change := Change{Kind: kind, Name: want.name, Action: ActionCreate}
+
+			if want.detailed {
+				change.Fields = createdFields("", want.fields)
+			}
+
+			d.Changes = append(d.Changes, change)
+			continue
+		}
+
+		if fields := diffFields("", want.fields, have.fields, want.defaults, want.subset); len(fields) > 0 {
+			d.Changes = append(d.Changes, Change{Kind: kind, Name: want.name, Action: updateAction(fields), Fields: fields})
+		} else {
+			d.unchanged = append(d.unchanged, Change{Kind: kind, Name: want.name, Action: ActionNone})
+		}
+	}
+
+	for _, have := range live {
+		if !seen[have.name] {
+			d.Changes = append(d.Changes, Change{Kind: kind, Name: have.name, Action: ActionDelete})
+		}
+	}
+}
+
+// diffFields compares the desired attributes against the live ones
+func diffFields(prefix string, desired, live, defaults Entity, subset bool) []FieldDiff {
+	fields := []FieldDiff{}
+	keys := make([]string, 0, len(desired))
+
+	for k := range desired {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		want := desired[k]
+
+		if want == nil && !subset {
+			want = defaults[k]
+		}
+
+		if want == nil && subset {
+			continue
+		}
+
+		wantMap, wantIsMap := want.(map[string]interface{})
+		haveMap, haveIsMap := live[k].(map[string]interface{})
+
+		if subset && wantIsMap && haveIsMap {
+			fields = append(fields, diffFields(prefix+k+".", wantMap, haveMap, nil, true)...)
+			continue
+		}
+
+		if !equalValues(want, live[k]) {
+			fields = append(fields, FieldDiff{Field: prefix + k, Old: live[k], New: want})
+		}
+	}
+
+	return fields
+}
+
+// createdFields lists every attribute of an entity to create, flattening objects
+func createdFields(prefix string, e Entity) []FieldDiff {
+	fields := []FieldDiff{}
+
+	for _, k := range sortedAttributes(e) {
+		if nested, ok := e[k].(map[string]interface{}); ok && len(nested) > 0 {
+			fields = append(fields, createdFields(prefix+k+".", nested)...)
+			continue
+		}
+
+		if e[k] != nil {
+			fields = append(fields, FieldDiff{Field: prefix + k, New: e[k]})
+		}
+	}
+
+	return fields
+}
+
+// equalValues compares two decoded JSON values, treating empty lists as null
+func equalValues(a, b interface{}) bool {
+	if isEmpty(a) && isEmpty(b) {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	if l, ok := v.([]interface{}); ok {
+		return len(l) == 0
+	}
+
+	return false
+}
+
+// toEntity converts any value into the same representation as a decoded API response
+func toEntity(v interface{}) Entity {
+	e := Entity{}
+	data, _ := json.Marshal(v)
+	json.Unmarshal(data, &e)
+
+	return e
+}
+
+// managedFields returns the JSON attribute names of a config struct
+func managedFields(v interface{}) []string {
+	fields := []string{}
+	t := reflect.TypeOf(v)
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
+
+// withFields returns an entity containing only the given attributes, set to
+// null when missing so omitted values are compared against Kong's defaults
+func withFields(e Entity, fields []string) Entity {
+	result := Entity{}
+
+	for _, f := range fields {
+		result[f] = e[f]
+	}
+
+	return result
+}
+
+func desiredServices(config *Config) []keyedEntity {
+	desired := []keyedEntity{}
+	fields := managedFields(Service{})
+
+	for _, s := range config.Services {
+		e := withFields(toEntity(s), fields)
+		expandServiceURL(e)
+
+		// Services are only enabled or disabled when the config says so
+		if s.Enabled == nil {
+			delete(e, "enabled")
+		}
+
+		desired = append(desired, keyedEntity{name: s.Name, fields: e, defaults: serviceDefaults})
+	}
+
+	return desired
+}
+
+// expandServiceURL replaces the url shorthand with the attributes Kong stores
+func expandServiceURL(e Entity) {
+	raw, _ := e["url"].(string)
+	delete(e, "url")
+
+	u, err := url.Parse(raw)
+
+	if raw == "" || err != nil {
+		return
+	}
+
+	e["protocol"] = u.Scheme
+	e["host"] = u.Hostname()
+
+	if u.Path != "" {
+		e["path"] = u.Path
+	}
+
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		e["port"] = float64(port)
+	} else if u.Scheme == "https" {
+		e["port"] = float64(443)
+	}
+}
+
+func desiredRoutes(config *Config) []keyedEntity {
+	desired := []keyedEntity{}
+	fields := managedFields(Route{})
+
+	for _, r := range config.Routes {
+		e := withFields(toEntity(r), fields)
+		delete(e, "id")
+		e["service"] = r.Service
+
+		desired = append(desired, keyedEntity{name: routeName(r.Name, r.Service, r.Paths), fields: e, defaults: routeDefaults})
+	}
+
+	return desired
+}
+
+// routeName identifies a route, falling back to its service and paths for unnamed routes
+func routeName(name, service string, paths []string) string {
+	if name != "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s%v", service, paths)
+}
+
+func desiredConsumers(config *Config) []keyedEntity {
+	desired := []keyedEntity{}
+	fields := managedFields(Consumer{})
+
+	for _, c := range config.Consumers {
+		desired = append(desired, keyedEntity{name: c.Username, fields: withFields(toEntity(c), fields)})
+	}
+
+	return desired
+}
+
+func desiredCredentials(config *Config) []keyedEntity {
+	desired := []keyedEntity{}
+
+	for _, c := range config.Credentials {
+		e := toEntity(c.Config)
+
+		for _, f := range credentialHashed[c.Name] {
+			delete(e, f)
+		}
+
+		name := credentialName(c.Name, c.Target, e[credentialKeys[c.Name]])
+		desired = append(desired, keyedEntity{name: name, fields: e, subset: true})
+	}
+
+	return desired
+}
+
+// credentialName identifies a credential by its type, consumer and key attribute
+func credentialName(plugin, consumer string, key interface{}) string {
+	if key == nil {
+		return fmt.Sprintf("%s for %s", plugin, consumer)
+	}
+
+	return fmt.Sprintf("%s %v for %s", plugin, key, consumer)
+}
+
+func desiredPlugins(config *Config) []keyedEntity {
+	desired := []keyedEntity{}
+
+	for _, p := range config.Plugins {
+		e := pluginEntity(p)
+
+		if p.Target == "global" {
+			desired = append(desired, keyedEntity{name: pluginName(p.Name, "", ""), fields: e, subset: true, detailed: true})
+			continue
+		}
+
+		for _, s := range p.Services {
+			desired = append(desired, keyedEntity{name: pluginName(p.Name, s, ""), fields: e, subset: true, detailed: true})
+		}
+
+		for _, r := range p.Routes {
+			desired = append(desired, keyedEntity{name: pluginName(p.Name, "", r), fields: e, subset: true, detailed: true})
+		}
+	}
+
+	return desired
+}
+
+// pluginEntity returns the attributes of a plugin as Kong stores them.
+// Kong enables plugins unless told otherwise
+func pluginEntity(p Plugin) Entity {
+	e := toEntity(p)
+	delete(e, "id")
+
+	if p.Enabled == nil {
+		e["enabled"] = true
+	}
+
+	return e
+}
+
+// updateAction returns the action needed to apply changed fields, enabling
+// and disabling being reported apart from other updates
+func updateAction(fields []FieldDiff) string {
+	for _, f := range fields {
+		if f.Field == "enabled" && f.New == true {
+			return ActionEnable
+		}
+
+		if f.Field == "enabled" && f.New == false {
+			return ActionDisable
+		}
+	}
+
+	return ActionUpdate
+}
+
+// pluginName identifies a plugin by its name and the entity it applies to
+func pluginName(name, service, route string) string {
+	switch {
+	case service != "":
+		return fmt.Sprintf("%s (service %s)", name, service)
+	case route != "":
+		return fmt.Sprintf("%s (route %s)", name, route)
+	default:
+		return fmt.Sprintf("%s (global)", name)
+	}
+}
+
+// liveIndex resolves the IDs Kong uses in foreign keys to the names used in the config
+type liveIndex struct {
+	snapshot      *Snapshot
+	serviceNames  map[string]string
+	routeNames    map[string]string
+	consumerNames map[string]string
+}
+
+func newLiveIndex(s *Snapshot) *liveIndex {
+	l := &liveIndex{
+		snapshot:      s,
+		serviceNames:  make(map[string]string),
+		routeNames:    make(map[string]string),
+		consumerNames: make(map[string]string),
+	}
+
+	refs := s
+
+	if s.source != nil {
+		refs = s.source
+	}
+
+	for _, e := range refs.Services {
+		l.serviceNames[e.ID()] = stringField(e, "name")
+	}
+
+	for _, e := range refs.Routes {
+		l.routeNames[e.ID()] = routeName(stringField(e, "name"), l.serviceNames[foreignID(e, "service")], stringList(e["paths"]))
+	}
+
+	for _, e := range refs.Consumers {
+		l.consumerNames[e.ID()] = stringField(e, "username")
+	}
+
+	return l
+}
+
+func (l *liveIndex) services() []keyedEntity {
+	live := []keyedEntity{}
+
+	for _, e := range l.snapshot.Services {
+		live = append(live, keyedEntity{name: stringField(e, "name"), fields: e})
+	}
+
+	return live
+}
+
+func (l *liveIndex) routes() []keyedEntity {
+	live := []keyedEntity{}
+
+	for _, e := range l.snapshot.Routes {
+		live = append(live, keyedEntity{name: l.routeNames[e.ID()], fields: l.routeFields(e)})
+	}
+
+	return live
+}
+
+// routeFields returns the attributes of a live route, referencing its service by name
+func (l *liveIndex) routeFields(e Entity) Entity {
+	fields := Entity{}
+
+	for k, v := range e {
+		fields[k] = v
+	}
+
+	fields["service"] = l.serviceNames[foreignID(e, "service")]
+
+	return fields
+}
+
+func (l *liveIndex) consumers() []keyedEntity {
+	live := []keyedEntity{}
+
+	for _, e := range l.snapshot.Consumers {
+		live = append(live, keyedEntity{name: stringField(e, "username"), fields: e})
+	}
+
+	return live
+}
+
+func (l *liveIndex) credentials() []keyedEntity {
+	live := []keyedEntity{}
+
+	for plugin, credentials := range l.snapshot.Credentials {
+		for _, e := range credentials {
+			name := credentialName(plugin, l.consumerNames[foreignID(e, "consumer")], e[credentialKeys[plugin]])
+			live = append(live, keyedEntity{name: name, fields: e})
+		}
+	}
+
+	return live
+}
+
+func (l *liveIndex) plugins() []keyedEntity {
+	live := []keyedEntity{}
+
+	for _, e := range l.snapshot.Plugins {
+		// Plugins applied to consumers are not managed by kongfig
+		if foreignID(e, "consumer") != "" {
+			continue
+		}
+
+		live = append(live, keyedEntity{name: l.pluginScope(e), fields: e})
+	}
+
+	return live
+}
+
+// pluginScope names a live plugin by its name and the entity it applies to
+func (l *liveIndex) pluginScope(e Entity) string {
+	if consumer := foreignID(e, "consumer"); consumer != "" {
+		return fmt.Sprintf("%s (consumer %s)", stringField(e, "name"), l.consumerNames[consumer])
+	}
+
+	return pluginName(stringField(e, "name"), l.serviceNames[foreignID(e, "service")], l.routeNames[foreignID(e, "route")])
+}
+
+// foreignID returns the ID of the entity referenced by attribute, eg: {"service": {"id": "..."}}
+func foreignID(e Entity, attribute string) string {
+	ref, _ := e[attribute].(map[string]interface{})
+	id, _ := ref["id"].(string)
+
+	return id
+}
+
+func stringField(e Entity, attribute string) string {
+	s, _ := e[attribute].(string)
+
+	return s
+}
+
+func stringList(v interface{}) []string {
+	list := []string{}
+	values, _ := v.([]interface{})
+
+	for _, value := range values {
+		list = append(list, fmt.Sprint(value))
+	}
+
+	return list
+}
+
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+
+	return string(data)
+}
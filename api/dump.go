@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	yaml "gopkg.in/mikefarah/yaml.v2"
+)
+
+var (
+	// Attributes of credentials set by Kong rather than the config
+	credentialInternal = []string{"id", "created_at", "consumer"}
+)
+
+// Dump fetches the live state of Kong as a config
+func (c *Client) Dump() (*Config, error) {
+	snapshot, err := c.TakeSnapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := ConfigFromSnapshot(snapshot)
+	config.Host = c.config.Host
+	config.HTTPS = c.config.HTTPS
+	config.Version = c.config.Version
+	config.Workspace = c.config.Workspace
+
+	return config, nil
+}
+
+// ConfigFromSnapshot converts the raw entities of a snapshot into a config.
+// Attributes left to their default value in Kong are omitted
+func ConfigFromSnapshot(s *Snapshot) *Config {
+	live := newLiveIndex(s)
+	config := &Config{}
+
+	for _, e := range s.Services {
+		config.Services = append(config.Services, Service{
+			Name:           stringField(e, "name"),
+			URL:            serviceURL(e),
+			ConnectTimeout: nonDefaultInt(e, serviceDefaults, "connect_timeout"),
+			WriteTimeout:   nonDefaultInt(e, serviceDefaults, "write_timeout"),
+			ReadTimeout:    nonDefaultInt(e, serviceDefaults, "read_timeout"),
+			Retries:        nonDefaultInt(e, serviceDefaults, "retries"),
+			Enabled:        disabled(e),
+			Tags:           stringSlice(e["tags"]),
+		})
+	}
+
+	for _, e := range s.Routes {
+		r := Route{
+			Name:          stringField(e, "name"),
+			Service:       live.serviceNames[foreignID(e, "service")],
+			Hosts:         stringSlice(e["hosts"]),
+			Paths:         stringSlice(e["paths"]),
+			Methods:       stringSlice(e["methods"]),
+			Headers:       headerMap(e["headers"]),
+			StripPath:     e["strip_path"] == true,
+			RegexPriority: nonDefaultInt(e, routeDefaults, "regex_priority"),
+			PreserveHost:  e["preserve_host"] == true,
+			Tags:          stringSlice(e["tags"]),
+		}
+
+		if !equalValues(e["protocols"], routeDefaults["protocols"]) {
+			r.Protocols = stringSlice(e["protocols"])
+		}
+
+		config.Routes = append(config.Routes, r)
+	}
+
+	for _, e := range s.Plugins {
+		// Plugins applied to consumers are not managed by kongfig
+		if foreignID(e, "consumer") != "" {
+			continue
+		}
+
+		p := Plugin{Name: stringField(e, "name"), Enabled: disabled(e), Tags: stringSlice(e["tags"])}
+		p.Config, _ = e["config"].(map[string]interface{})
+
+		switch {
+		case foreignID(e, "service") != "":
+			p.Services = []string{live.serviceNames[foreignID(e, "service")]}
+		case foreignID(e, "route") != "":
+			p.Routes = []string{live.routeNames[foreignID(e, "route")]}
+		default:
+			p.Target = "global"
+		}
+
+		config.Plugins = append(config.Plugins, p)
+	}
+
+	for _, e := range s.Consumers {
+		config.Consumers = append(config.Consumers, Consumer{
+			Username: stringField(e, "username"),
+			CustomID: stringField(e, "custom_id"),
+			Tags:     stringSlice(e["tags"]),
+		})
+	}
+
+	for _, name := range sortedKeys(s.Credentials) {
+		for _, e := range s.Credentials[name] {
+			cred := Credential{Name: name, Target: live.consumerNames[foreignID(e, "consumer")], Config: map[string]interface{}{}}
+
+			for k, v := range e {
+				cred.Config[k] = v
+			}
+
+			for _, k := range credentialInternal {
+				delete(cred.Config, k)
+			}
+
+			config.Credentials = append(config.Credentials, cred)
+		}
+	}
+
+	return config
+}
+
+// WriteConfig writes a config in one of the output formats: text writes the
+// YAML config file, json the same structure as JSON and jsonl one entity per line
+func WriteConfig(w io.Writer, format string, config *Config) error {
+	switch format {
+	case OutputText:
+		data, err := yaml.Marshal(config)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(data)
+
+		return err
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(toYAMLEntity(config))
+	case OutputJSONL:
+		enc := json.NewEncoder(w)
+
+		for _, line := range configLines(config) {
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Output format %s is not supported when dumping a config", format)
+}
+
+// configLine represents a single entity of a config in the jsonl output format
+type configLine struct {
+	Entity string      `json:"entity"`
+	Name   string      `json:"name"`
+	Data   interface{} `json:"data"`
+}
+
+func configLines(config *Config) []configLine {
+	lines := []configLine{}
+
+	for _, s := range config.Services {
+		lines = append(lines, configLine{KindService, s.Name, toYAMLEntity(s)})
+	}
+
+	for _, r := range config.Routes {
+		lines = append(lines, configLine{KindRoute, routeName(r.Name, r.Service, r.Paths), toYAMLEntity(r)})
+	}
+
+	for _, c := range config.Consumers {
+		lines = append(lines, configLine{KindConsumer, c.Username, toYAMLEntity(c)})
+	}
+
+	for _, c := range config.Credentials {
+		lines = append(lines, configLine{KindCredential, credentialName(c.Name, c.Target, c.Config[credentialKeys[c.Name]]), toYAMLEntity(c)})
+	}
+
+	for _, p := range config.Plugins {
+		lines = append(lines, configLine{KindPlugin, p.Name, toYAMLEntity(p)})
+	}
+
+	return lines
+}
+
+// toYAMLEntity converts a config struct into a map keyed by its YAML attribute
+// names, keeping the attributes hidden from the Admin API payloads such as apply_to
+func toYAMLEntity(v interface{}) map[string]interface{} {
+	e := map[string]interface{}{}
+	data, _ := yaml.Marshal(v)
+	yaml.Unmarshal(data, &e)
+
+	return e
+}
+
+// serviceURL builds the url shorthand of a service from the attributes Kong stores
+func serviceURL(e Entity) string {
+	url := fmt.Sprintf("%s://%s", stringField(e, "protocol"), stringField(e, "host"))
+
+	if port, ok := e["port"].(float64); ok && !isDefaultPort(stringField(e, "protocol"), int(port)) {
+		url = fmt.Sprintf("%s:%d", url, int(port))
+	}
+
+	return url + stringField(e, "path")
+}
+
+func isDefaultPort(protocol string, port int) bool {
+	return (protocol == "http" && port == 80) || (protocol == "https" && port == 443)
+}
+
+// nonDefaultInt returns the integer value of an attribute, or 0 when it is set to its default
+func nonDefaultInt(e, defaults Entity, attribute string) int {
+	v, _ := e[attribute].(float64)
+
+	if reflect.DeepEqual(e[attribute], defaults[attribute]) {
+		return 0
+	}
+
+	return int(v)
+}
+
+func stringSlice(v interface{}) []string {
+	if isEmpty(v) {
+		return nil
+	}
+
+	return stringList(v)
+}
+
+func headerMap(v interface{}) map[string][]string {
+	headers, _ := v.(map[string]interface{})
+
+	if len(headers) == 0 {
+		return nil
+	}
+
+	m := make(map[string][]string)
+
+	for name, values := range headers {
+		m[name] = stringList(values)
+	}
+
+	return m
+}
+
+func sortedKeys(m map[string][]Entity) []string {
+	keys := []string{}
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// disabled returns false when the entity is disabled, nil otherwise, as
+// entities are enabled by default
+func disabled(e Entity) *bool {
+	if enabled, ok := e["enabled"].(bool); ok && !enabled {
+		return &enabled
+	}
+
+	return nil
+}
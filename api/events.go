@@ -0,0 +1,325 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entity types reported in events besides the ones of the config
+const (
+	KindSnapshot  string = "snapshot"
+	KindWorkspace string = "workspace"
+)
+
+// Actions reported in events besides the ones of a diff
+const (
+	ActionSave    string = "save"
+	ActionRestore string = "restore"
+	ActionNone    string = "none"
+)
+
+// Outcome of an operation
+const (
+	StatusOK     string = "ok"
+	StatusFailed string = "failed"
+	StatusDrift  string = "drift"
+	// StatusSkipped reports a cluster a command was not run against
+	StatusSkipped string = "skipped"
+)
+
+// Output formats supported by NewReporter
+const (
+	OutputText  string = "text"
+	OutputJSON  string = "json"
+	OutputJSONL string = "jsonl"
+	OutputJUnit string = "junit"
+)
+
+// Event represents the outcome of a single operation against Kong
+type Event struct {
+	// Cluster the operation was performed on, empty when the config has a single host
+	Cluster    string        `json:"cluster,omitempty"`
+	Entity     string        `json:"entity"`
+	Name       string        `json:"name"`
+	Action     string        `json:"action"`
+	Status     string        `json:"status"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Duration   time.Duration `json:"-"`
+	Error      string        `json:"error,omitempty"`
+	Fields     []FieldDiff   `json:"fields,omitempty"`
+}
+
+// MarshalJSON encodes the duration of the event in milliseconds
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+
+	return json.Marshal(struct {
+		event
+		DurationMS float64 `json:"duration_ms"`
+	}{event(e), float64(e.Duration) / float64(time.Millisecond)})
+}
+
+// Reporter receives an event for every operation kongfig performs
+type Reporter interface {
+	Report(e Event)
+	// Close writes any buffered output
+	Close() error
+}
+
+// NewReporter returns a Reporter writing events to w in the given format
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case OutputText:
+		return &textReporter{w: w}, nil
+	case OutputJSON:
+		return &jsonReporter{w: w, events: []Event{}}, nil
+	case OutputJSONL:
+		return &jsonlReporter{enc: json.NewEncoder(w)}, nil
+	case OutputJUnit:
+		return &junitReporter{w: w}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown output format: %s", format)
+}
+
+// textReporter prints one human readable line per event
+type textReporter struct {
+	w io.Writer
+}
+
+func (r *textReporter) Report(e Event) {
+	prefix := ""
+
+	if e.Cluster != "" {
+		prefix = fmt.Sprintf("[%s] ", e.Cluster)
+	}
+
+	if e.StatusCode != 0 {
+		prefix += fmt.Sprintf("[HTTP %d] ", e.StatusCode)
+	}
+
+	switch e.Status {
+	case StatusFailed:
+		fmt.Fprintf(r.w, "%sFailed to %s %s %s: %s \n", prefix, e.Action, e.Entity, e.Name, e.Error)
+	case StatusDrift:
+		fmt.Fprintf(r.w, "%s%s %s needs %s \n", prefix, capitalize(e.Entity), e.Name, e.Action)
+	default:
+		fmt.Fprintf(r.w, "%s%s %s %s \n", prefix, capitalize(pastTense(e.Action)), e.Entity, e.Name)
+	}
+}
+
+func (r *textReporter) Close() error {
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func pastTense(action string) string {
+	switch {
+	case action == ActionNone:
+		return "unchanged"
+	case strings.HasSuffix(action, "e"):
+		return action + "d"
+	default:
+		return action + "ed"
+	}
+}
+
+// jsonReporter writes all events as a single JSON array once closed
+type jsonReporter struct {
+	w      io.Writer
+	events []Event
+}
+
+func (r *jsonReporter) Report(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *jsonReporter) Close() error {
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r.events)
+}
+
+// jsonlReporter writes every event as a JSON object on its own line
+type jsonlReporter struct {
+	enc *json.Encoder
+}
+
+func (r *jsonlReporter) Report(e Event) {
+	r.enc.Encode(e)
+}
+
+func (r *jsonlReporter) Close() error {
+	return nil
+}
+
+// junitReporter writes a JUnit XML report once closed, with a test suite per
+// entity type, and cluster if any, and a test case per event
+type junitReporter struct {
+	w      io.Writer
+	events []Event
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func (r *junitReporter) Report(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *junitReporter) Close() error {
+	report := junitTestSuites{Name: "kongfig"}
+	suites := make(map[string]*junitTestSuite)
+	names := []string{}
+
+	for _, e := range r.events {
+		name := e.Entity
+
+		if e.Cluster != "" {
+			name = e.Cluster + "." + e.Entity
+		}
+
+		suite, ok := suites[name]
+
+		if !ok {
+			suite = &junitTestSuite{Name: name}
+			suites[name] = suite
+			names = append(names, name)
+		}
+
+		tc := junitTestCase{
+			ClassName: "kongfig." + name,
+			Name:      fmt.Sprintf("%s %s", e.Action, e.Name),
+			Time:      e.Duration.Seconds(),
+		}
+
+		if e.Status != StatusOK {
+			tc.Failure = &junitFailure{Message: e.Error, Type: e.Status}
+
+			if e.Status == StatusDrift {
+				tc.Failure.Message = fmt.Sprintf("%s %s needs %s", e.Entity, e.Name, e.Action)
+			}
+
+			for _, f := range e.Fields {
+				tc.Failure.Body += fmt.Sprintf("%s: %s => %s\n", f.Field, jsonString(f.Old), jsonString(f.New))
+			}
+
+			suite.Failures++
+			report.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		suite.Time += tc.Time
+		report.Tests++
+		report.Time += tc.Time
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		report.Suites = append(report.Suites, *suites[name])
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(r.w, "%s%s\n", xml.Header, data)
+
+	return err
+}
+
+// syncReporter lets several clients report events at once to the same reporter
+type syncReporter struct {
+	mu sync.Mutex
+	r  Reporter
+}
+
+// SyncReporter returns a Reporter safe to share between clients running in parallel
+func SyncReporter(r Reporter) Reporter {
+	return &syncReporter{r: r}
+}
+
+func (r *syncReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.r.Report(e)
+}
+
+func (r *syncReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Close()
+}
+
+// track runs an operation against Kong and reports its outcome. The operation
+// returns the HTTP status code of the response, if any
+func (c *Client) track(entity, name, action string, op func() (int, error)) error {
+	start := time.Now()
+	code, err := op()
+
+	e := Event{
+		Cluster:    c.Cluster,
+		Entity:     entity,
+		Name:       name,
+		Action:     action,
+		Status:     StatusOK,
+		StatusCode: code,
+		Duration:   time.Since(start),
+	}
+
+	if err != nil {
+		e.Status = StatusFailed
+		e.Error = err.Error()
+	}
+
+	c.Reporter.Report(e)
+
+	return err
+}
@@ -0,0 +1,77 @@
+//go:build ignore
+// +build ignore
+
+// gen_schemas bundles the plugin and credential schemas stored in the
+// schemas directory, as <kong version>/<kind>/<name>.json, into kongfig
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	schemasDir = "schemas"
+	output     = "schemas_bundled.go"
+)
+
+func main() {
+	files, err := filepath.Glob(filepath.Join(schemasDir, "*", "*", "*.json"))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sort.Strings(files)
+
+	versions := make(map[string][]string)
+	order := []string{}
+
+	for _, file := range files {
+		parts := strings.Split(filepath.ToSlash(file), "/")
+		version, kind, name := parts[1], parts[2], strings.TrimSuffix(parts[3], ".json")
+
+		data, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if _, ok := versions[version]; !ok {
+			order = append(order, version)
+		}
+
+		entry := fmt.Sprintf("%s: %s,", strconv.Quote(kind+"/"+name), strconv.Quote(string(bytes.TrimSpace(data))))
+		versions[version] = append(versions[version], entry)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by gen_schemas.go; DO NOT EDIT.\n\npackage api\n\n")
+	fmt.Fprintf(buf, "// Schemas of core plugins and credentials, keyed by Kong version\nvar bundled = map[string]bundledSchemas{\n")
+
+	for _, version := range order {
+		fmt.Fprintf(buf, "%s: {\n%s\n},\n", strconv.Quote(version), strings.Join(versions[version], "\n"))
+	}
+
+	fmt.Fprintf(buf, "}\n")
+
+	source, err := format.Source(buf.Bytes())
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(output, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Bundled %d schemas of %d Kong version(s) into %s\n", len(files), len(order), output)
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultJournalDir string = ".kongfig/journals"
+
+// Journal records the operations of an apply and their progress, so an apply
+// interrupted halfway can be resumed
+type Journal struct {
+	Host       string    `json:"host"`
+	CreatedAt  time.Time `json:"created_at"`
+	ConfigHash string    `json:"config_hash"`
+	// Snapshot is the path of the snapshot taken before applying
+	Snapshot   string      `json:"snapshot,omitempty"`
+	RolledBack bool        `json:"rolled_back,omitempty"`
+	Operations []Operation `json:"operations"`
+
+	path string
+}
+
+// LoadJournal reads a journal written by an apply
+func LoadJournal(path string) (*Journal, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{path: path}
+
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("Error parsing journal %s: %s", path, err)
+	}
+
+	return j, nil
+}
+
+// Path returns the file the journal is written to
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Pending returns the number of operations not done yet
+func (j *Journal) Pending() int {
+	pending := 0
+
+	for _, op := range j.Operations {
+		if op.Status != OperationDone {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+// save writes the journal to its file, replacing the previous version at once
+// so an interrupted write never leaves a truncated journal
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
+
+// newJournal writes the journal of a plan to a timestamped file inside JournalDir
+func (c *Client) newJournal(plan *Plan, snapshot string) (*Journal, error) {
+	if err := os.MkdirAll(c.JournalDir, 0755); err != nil {
+		return nil, err
+	}
+
+	j := &Journal{
+		Host:       c.BaseURL,
+		CreatedAt:  time.Now().UTC(),
+		ConfigHash: plan.ConfigHash,
+		Snapshot:   snapshot,
+		Operations: plan.Operations,
+	}
+
+	j.path = filepath.Join(c.JournalDir, fmt.Sprintf("kongfig-journal-%s.json", j.CreatedAt.Format(snapshotTimeFormat)))
+
+	return j, j.save()
+}
+
+// execute performs the operations of the journal not done yet, in order,
+// recording their progress before and after every request
+func (c *Client) execute(j *Journal) error {
+	for i := range j.Operations {
+		op := &j.Operations[i]
+
+		if op.Status == OperationDone {
+			continue
+		}
+
+		op.Status = OperationStarted
+
+		if err := j.save(); err != nil {
+			return fmt.Errorf("Error writing journal %s: %s", j.path, err)
+		}
+
+		if err := c.track(op.Kind, op.Name, op.Action, func() (int, error) { return c.perform(op) }); err != nil {
+			return err
+		}
+
+		op.Status = OperationDone
+
+		if err := j.save(); err != nil {
+			return fmt.Errorf("Error writing journal %s: %s", j.path, err)
+		}
+	}
+
+	return nil
+}
+
+// Resume continues an apply interrupted halfway from its journal. The config
+// must be the one being applied, and the entities changed by the completed
+// operations must still be in the state they left them. The apply lock is
+// held meanwhile
+func (c *Client) Resume(path string) error {
+	j, err := LoadJournal(path)
+
+	if err != nil {
+		return err
+	}
+
+	if j.RolledBack {
+		return fmt.Errorf("The apply of journal %s was rolled back, apply the config again instead", path)
+	}
+
+	if j.Host != c.BaseURL {
+		return fmt.Errorf("Journal %s was written for %s, not %s", path, j.Host, c.BaseURL)
+	}
+
+	if j.ConfigHash != configHash(c.config) {
+		return fmt.Errorf("The config changed since journal %s was written, apply it again instead", path)
+	}
+
+	if j.Pending() == 0 {
+		c.Logger.Infof("Every operation of journal %s is done, nothing to resume", path)
+		return nil
+	}
+
+	if !c.DisableLock {
+		lock, err := c.AcquireLock()
+
+		if err != nil {
+			return err
+		}
+
+		defer lock.Release()
+	}
+
+	live, err := c.TakeSnapshot()
+
+	if err != nil {
+		return err
+	}
+
+	if err := j.verify(live); err != nil {
+		return fmt.Errorf("Kong changed since journal %s was written, not resuming: %s", path, err)
+	}
+
+	c.Logger.Infof("Resuming %d of %d operation(s) from journal %s", j.Pending(), len(j.Operations), path)
+
+	var snapshot *Snapshot
+
+	if j.Snapshot != "" {
+		if snapshot, err = LoadSnapshot(j.Snapshot); err != nil {
+			c.Logger.Errorf("Error loading snapshot %s, Kong won't be rolled back on failure: %s", j.Snapshot, err)
+		}
+	}
+
+	return c.run(j, snapshot)
+}
+
+// run executes a journal, rolling Kong back to snapshot when it fails
+func (c *Client) run(j *Journal, snapshot *Snapshot) error {
+	err := c.execute(j)
+
+	if err == nil {
+		return nil
+	}
+
+	if c.DisableRollback || snapshot == nil {
+		c.Logger.Errorf("Error applying config, resume with `kongfig apply --resume %s`", j.path)
+		return err
+	}
+
+	c.Logger.Errorf("Error applying config, rolling back to snapshot %s: %s", j.Snapshot, err)
+
+	if rerr := c.Restore(snapshot); rerr != nil {
+		c.Logger.Errorf("Rollback failed, resume with `kongfig apply --resume %s` or restore with `kongfig rollback --snapshot %s`", j.path, j.Snapshot)
+		return fmt.Errorf("%s; rollback failed: %s (restore manually with `kongfig rollback --snapshot %s`)", err, rerr, j.Snapshot)
+	}
+
+	j.RolledBack = true
+
+	if serr := j.save(); serr != nil {
+		c.Logger.Errorf("Error writing journal %s: %s", j.path, serr)
+	}
+
+	return err
+}
+
+// verify checks that the entities created or deleted by the completed
+// operations still exist or are still gone. Started operations whose change
+// is visible are marked as done, the others are performed again
+func (j *Journal) verify(s *Snapshot) error {
+	present := liveKeys(s)
+	expected := make(map[string]bool)
+
+	for i := range j.Operations {
+		op := &j.Operations[i]
+		key := op.key()
+
+		if key == "" || op.Status == OperationPending {
+			continue
+		}
+
+		exists := op.Action != ActionDelete
+
+		// Updates can safely be performed again, creations and deletions only when not visible
+		if op.Status == OperationStarted {
+			if (op.Action == ActionCreate || op.Action == ActionDelete) && present[key] == exists {
+				op.Status = OperationDone
+			} else {
+				op.Status = OperationPending
+				continue
+			}
+		}
+
+		expected[key] = exists
+	}
+
+	mismatches := []string{}
+
+	for key, exists := range expected {
+		if present[key] != exists {
+			state := "missing"
+
+			if !exists {
+				state = "present again"
+			}
+
+			mismatches = append(mismatches, fmt.Sprintf("%s is %s", strings.Replace(key, "\x00", " ", 1), state))
+		}
+	}
+
+	sort.Strings(mismatches)
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%s", strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+// key identifies the entity changed by an operation, matching liveKeys.
+// Credentials created without their key attribute can't be identified
+func (op *Operation) key() string {
+	if op.Kind == KindCredential && op.Action == ActionCreate && op.Body[credentialKeys[path.Base(op.Path)]] == nil {
+		return ""
+	}
+
+	return op.Kind + "\x00" + op.Name
+}
+
+// liveKeys returns the keys of the entities of a snapshot, by name and by ID
+func liveKeys(s *Snapshot) map[string]bool {
+	live := newLiveIndex(s)
+	keys := make(map[string]bool)
+
+	add := func(kind string, e Entity, name string) {
+		keys[kind+"\x00"+e.ID()] = true
+		keys[kind+"\x00"+name] = true
+	}
+
+	for _, e := range s.Services {
+		add(KindService, e, e.displayName())
+	}
+
+	for _, e := range s.Routes {
+		add(KindRoute, e, live.routeNames[e.ID()])
+	}
+
+	for _, e := range s.Consumers {
+		add(KindConsumer, e, e.displayName())
+	}
+
+	for plugin, credentials := range s.Credentials {
+		for _, e := range credentials {
+			add(KindCredential, e, credentialName(plugin, live.consumerNames[foreignID(e, "consumer")], e[credentialKeys[plugin]]))
+		}
+	}
+
+	for _, e := range s.Plugins {
+		add(KindPlugin, e, live.pluginScope(e))
+	}
+
+	return keys
+}
+
+// configHash identifies a config, to resume journals with the config they were written for
+func configHash(config *Config) string {
+	data, _ := json.Marshal(config)
+	sum := md5.Sum(data)
+
+	return hex.EncodeToString(sum[:])
+}
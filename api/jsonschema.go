@@ -0,0 +1,115 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	protocols   = []string{"http", "https", "grpc", "grpcs", "tcp", "tls"}
+	httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+	// Allowed values of config attributes, keyed by struct and YAML attribute
+	schemaEnums = map[string][]string{
+		"Service.protocol": protocols,
+		"Route.protocols":  protocols,
+		"Route.methods":    httpMethods,
+		"Plugin.target":    {"global"},
+		"Credential.name":  sortedCredentialTypes(),
+	}
+
+	// Attributes every entity of a struct must define
+	schemaRequired = map[string][]string{
+		"Service":        {"name"},
+		"PluginTemplate": {"name"},
+		"Consumer":       {"username"},
+		"Credential":     {"name", "target"},
+		"Cluster":        {"name", "host"},
+	}
+)
+
+// ConfigSchema returns a JSON Schema describing the config file. It is
+// generated from the YAML attributes of Config, so it follows its changes
+func ConfigSchema() map[string]interface{} {
+	definitions := map[string]interface{}{}
+	schema := structSchema(reflect.TypeOf(Config{}), definitions)
+
+	// Extension attributes can hold YAML anchors reused across the config
+	schema["patternProperties"] = map[string]interface{}{"^x-": map[string]interface{}{}}
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "kongfig configuration"
+	schema["definitions"] = definitions
+
+	return schema
+}
+
+// structSchema describes the YAML attributes of a struct, adding the schemas
+// of nested structs to definitions
+func structSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := typeSchema(f.Type, definitions)
+
+		if enum, ok := schemaEnums[t.Name()+"."+name]; ok {
+			if items, ok := property["items"].(map[string]interface{}); ok {
+				items["enum"] = enum
+			} else {
+				property["enum"] = enum
+			}
+		}
+
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if required, ok := schemaRequired[t.Name()]; ok {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// typeSchema describes a Go type as decoded from YAML
+func typeSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), definitions)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), definitions)}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]interface{}{"type": "object"}
+		}
+
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), definitions)}
+	case reflect.Struct:
+		if _, ok := definitions[t.Name()]; !ok {
+			definitions[t.Name()] = structSchema(t, definitions)
+		}
+
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	}
+
+	return map[string]interface{}{}
+}
@@ -0,0 +1,490 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severities of lint issues
+const (
+	SeverityError   string = "error"
+	SeverityWarning string = "warning"
+)
+
+// Lint rules, which can be suppressed per route with a
+// "# kongfig-lint:ignore <rule>[,<rule>]" comment
+const (
+	RuleInvalidRegex       string = "invalid-regex"
+	RuleUnknownService     string = "unknown-service"
+	RuleIdenticalMatchers  string = "identical-matchers"
+	RuleShadowedRegex      string = "shadowed-regex"
+	RuleAmbiguousPriority  string = "ambiguous-priority"
+	RuleHTTPOnHTTPSService string = "http-on-https-service"
+)
+
+const suppressionComment = "kongfig-lint:ignore"
+
+var (
+	// Characters ending the literal prefix of a regex path
+	regexMeta = ".^$*+?()[]{}|\\"
+	// Name attribute of an entity in a YAML list, as in "- name: foo" or "name: foo"
+	nameLine = regexp.MustCompile(`^\s*(?:-\s+)?name:\s*["']?([^"'#\s]+)`)
+	// Routes attribute of the config or of a service, capturing its indentation
+	routesKey = regexp.MustCompile(`^(\s*(?:-\s+)?)routes:\s*(?:#.*)?$`)
+)
+
+// Issue represents a problem found in a config
+type Issue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+}
+
+// Validation represents the issues found in a config
+type Validation struct {
+	Issues []Issue
+	// Number of issues silenced by suppression comments
+	Suppressed int
+}
+
+// Errors returns the number of issues with error severity
+func (v *Validation) Errors() int {
+	count := 0
+
+	for _, i := range v.Issues {
+		if i.Severity == SeverityError {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Warnings returns the number of issues with warning severity
+func (v *Validation) Warnings() int {
+	return len(v.Issues) - v.Errors()
+}
+
+// WriteText writes one line per issue followed by a summary
+func (v *Validation) WriteText(w io.Writer) {
+	for _, i := range v.Issues {
+		fmt.Fprintf(w, "%-7s %s %s: %s (%s)\n", i.Severity, i.Kind, i.Name, i.Message, i.Rule)
+	}
+
+	if len(v.Issues) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "%d error(s), %d warning(s), %d suppressed\n", v.Errors(), v.Warnings(), v.Suppressed)
+}
+
+// WriteJSON writes the issues as a JSON report
+func (v *Validation) WriteJSON(w io.Writer) error {
+	report := struct {
+		Errors     int     `json:"errors"`
+		Warnings   int     `json:"warnings"`
+		Suppressed int     `json:"suppressed"`
+		Issues     []Issue `json:"issues"`
+	}{v.Errors(), v.Warnings(), v.Suppressed, v.Issues}
+
+	if report.Issues == nil {
+		report.Issues = []Issue{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(report)
+}
+
+// ValidateFile parses the config at path and lints it, honoring the
+// suppression comments of the file. Plugin and credential configs are
+// validated against the schemas of source, unless it is nil
+func ValidateFile(path string, source SchemaSource) (*Validation, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig([]byte(os.ExpandEnv(string(data))))
+
+	if err != nil {
+		return nil, err
+	}
+
+	issues := LintRoutes(config)
+
+	if source != nil {
+		pluginIssues, err := LintPlugins(config, source)
+
+		if err != nil {
+			return nil, err
+		}
+
+		issues = append(issues, pluginIssues...)
+	}
+
+	return Suppress(issues, Suppressions(data)), nil
+}
+
+// Suppress removes the route issues silenced by suppressions, as returned by Suppressions
+func Suppress(issues []Issue, suppressions map[string][]string) *Validation {
+	v := &Validation{}
+
+	for _, i := range issues {
+		if i.Kind == KindRoute && suppressed(suppressions[i.Name], i.Rule) {
+			v.Suppressed++
+			continue
+		}
+
+		v.Issues = append(v.Issues, i)
+	}
+
+	return v
+}
+
+func suppressed(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule || r == "all" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Suppressions finds the "# kongfig-lint:ignore" comments of the routes of a
+// config file, top-level or nested under services, either on the line before
+// a route or on any of its lines. It returns the suppressed rules keyed by route name
+func Suppressions(data []byte) map[string][]string {
+	suppressions := make(map[string][]string)
+	// Columns of the routes key, of the dash of its items and of the attributes of an item
+	routesColumn, itemColumn, keyColumn := -1, -1, -1
+	pending, current := []string{}, []string{}
+	name := ""
+
+	flush := func() {
+		if name != "" && len(current) > 0 {
+			suppressions[name] = append(suppressions[name], current...)
+		}
+
+		name, current = "", []string{}
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		column := len(line) - len(strings.TrimLeft(line, " "))
+
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			pending = append(pending, suppressionRules(trimmed)...)
+			continue
+		}
+
+		item := strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+
+		if routesColumn >= 0 {
+			switch {
+			case item && (column == itemColumn || (itemColumn < 0 && column >= routesColumn)):
+				flush()
+				itemColumn = column
+				keyColumn = column + 1 + len(trimmed[1:]) - len(strings.TrimLeft(trimmed[1:], " "))
+				current, pending = pending, []string{}
+			case column <= routesColumn:
+				flush()
+				routesColumn, itemColumn = -1, -1
+			}
+		}
+
+		if routesColumn >= 0 {
+			current = append(current, suppressionRules(line)...)
+
+			if m := nameLine.FindStringSubmatch(line); m != nil && strings.Index(line, "name:") == keyColumn {
+				name = m[1]
+			}
+
+			continue
+		}
+
+		pending = []string{}
+
+		if m := routesKey.FindStringSubmatch(line); m != nil {
+			routesColumn = len(m[1])
+		}
+	}
+
+	flush()
+
+	return suppressions
+}
+
+// suppressionRules returns the rules listed in a suppression comment of line,
+// "all" when the comment lists none
+func suppressionRules(line string) []string {
+	i := strings.Index(line, "#")
+
+	if i < 0 {
+		return nil
+	}
+
+	comment := strings.TrimSpace(line[i+1:])
+
+	if !strings.HasPrefix(comment, suppressionComment) {
+		return nil
+	}
+
+	rules := []string{}
+
+	for _, r := range strings.FieldsFunc(strings.TrimPrefix(comment, suppressionComment), func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\t'
+	}) {
+		rules = append(rules, r)
+	}
+
+	if len(rules) == 0 {
+		rules = append(rules, "all")
+	}
+
+	return rules
+}
+
+// issueFunc records an issue found in a route
+type issueFunc func(rule, severity string, r Route, format string, args ...interface{})
+
+// LintRoutes checks the routes of a config for invalid regexes, unknown
+// services, routes Kong can never reach or evaluates in an undefined order,
+// and plain HTTP routes to HTTPS services
+func LintRoutes(config *Config) []Issue {
+	issues := []Issue{}
+
+	var issue issueFunc = func(rule, severity string, r Route, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Rule:     rule,
+			Severity: severity,
+			Kind:     KindRoute,
+			Name:     routeName(r.Name, r.Service, r.Paths),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	services := make(map[string]Service)
+
+	for _, s := range config.Services {
+		services[s.Name] = s
+	}
+
+	valid := []int{}
+
+	for i, r := range config.Routes {
+		ok := true
+
+		for _, p := range r.Paths {
+			if IsRegexPath(p) {
+				if _, err := CompileRegexPath(p); err != nil {
+					issue(RuleInvalidRegex, SeverityError, r, "path %s is not a valid regex: %s", p, err)
+					ok = false
+				}
+			}
+		}
+
+		s, found := services[r.Service]
+
+		if !found {
+			issue(RuleUnknownService, SeverityError, r, "applies to unknown service %s", r.Service)
+		} else if target, err := serviceTarget(s); err == nil && target.Scheme == "https" && containsFold(routeProtocols(r), "http") {
+			issue(RuleHTTPOnHTTPSService, SeverityWarning, r, "accepts plain http requests to service %s, which is only reachable over https", s.Name)
+		}
+
+		if ok {
+			valid = append(valid, i)
+		}
+	}
+
+	for x, i := range valid {
+		a := config.Routes[i]
+
+		for _, j := range valid[x+1:] {
+			b := config.Routes[j]
+
+			if matcherKey(a) == matcherKey(b) {
+				issue(RuleIdenticalMatchers, SeverityError, b, "has the same matchers as route %s and can never be reached", routeName(a.Name, a.Service, a.Paths))
+				continue
+			}
+
+			if !attributesOverlap(a, b) {
+				continue
+			}
+
+			shadowedPaths(a, b, issue)
+			shadowedPaths(b, a, issue)
+			ambiguousPaths(a, b, issue)
+		}
+	}
+
+	return issues
+}
+
+// shadowedPaths reports the regex paths of a that prefix paths of b take
+// requests from, because b defines more matching attributes
+func shadowedPaths(a, b Route, issue issueFunc) {
+	if conditions(b) <= conditions(a) {
+		return
+	}
+
+	for _, regex := range a.Paths {
+		if !IsRegexPath(regex) {
+			continue
+		}
+
+		for _, p := range b.Paths {
+			if !IsRegexPath(p) && strings.HasPrefix(literalPrefix(regex), p) {
+				issue(RuleShadowedRegex, SeverityWarning, a, "regex path %s is shadowed by prefix path %s of route %s, which defines more matching attributes", regex, p, routeName(b.Name, b.Service, b.Paths))
+			}
+		}
+	}
+}
+
+// ambiguousPaths reports the regex paths of b overlapping regex paths of a
+// with the same precedence, which Kong evaluates in an undefined order
+func ambiguousPaths(a, b Route, issue issueFunc) {
+	if conditions(a) != conditions(b) || a.RegexPriority != b.RegexPriority {
+		return
+	}
+
+	for _, regex := range a.Paths {
+		for _, p := range b.Paths {
+			if !IsRegexPath(regex) || !IsRegexPath(p) {
+				continue
+			}
+
+			pa, pb := literalPrefix(regex), literalPrefix(p)
+
+			if strings.HasPrefix(pa, pb) || strings.HasPrefix(pb, pa) {
+				issue(RuleAmbiguousPriority, SeverityWarning, b, "regex path %s overlaps regex path %s of route %s with the same regex_priority %d, Kong evaluates them in an undefined order", p, regex, routeName(a.Name, a.Service, a.Paths), a.RegexPriority)
+			}
+		}
+	}
+}
+
+// literalPrefix returns the part of a regex path every matching request path starts with
+func literalPrefix(path string) string {
+	i := strings.IndexAny(path, regexMeta)
+
+	if i < 0 {
+		return path
+	}
+
+	// A quantifier makes the preceding character optional
+	if i > 0 && strings.ContainsAny(path[i:i+1], "*?{") {
+		i--
+	}
+
+	return path[:i]
+}
+
+// conditions returns the number of matching attributes of a route, which decides its precedence
+func conditions(r Route) int {
+	count := 0
+
+	for _, n := range []int{len(r.Hosts), len(r.Headers), len(r.Methods), len(r.Paths)} {
+		if n > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// routeProtocols returns the protocols of a route, which Kong defaults to http and https
+func routeProtocols(r Route) []string {
+	if len(r.Protocols) == 0 {
+		return []string{"http", "https"}
+	}
+
+	return r.Protocols
+}
+
+// attributesOverlap reports whether a request can match the protocols,
+// hosts, methods and headers of both routes
+func attributesOverlap(a, b Route) bool {
+	if !listsOverlap(routeProtocols(a), routeProtocols(b)) || !listsOverlap(a.Methods, b.Methods) {
+		return false
+	}
+
+	if len(a.Hosts) > 0 && len(b.Hosts) > 0 {
+		overlap := false
+
+		for _, ha := range a.Hosts {
+			for _, hb := range b.Hosts {
+				overlap = overlap || strings.EqualFold(ha, hb) || matchHost(ha, hb) || matchHost(hb, ha)
+			}
+		}
+
+		if !overlap {
+			return false
+		}
+	}
+
+	for name, values := range a.Headers {
+		if other := headerValues(b.Headers, name); other != nil && !containsAnyFold(values, other) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listsOverlap reports whether two optional lists share a value, an empty list matching anything
+func listsOverlap(a, b []string) bool {
+	return len(a) == 0 || len(b) == 0 || containsAnyFold(a, b)
+}
+
+// matcherKey returns a canonical representation of the matching attributes of a route
+func matcherKey(r Route) string {
+	normalize := func(list []string, defaults ...string) string {
+		if len(list) == 0 {
+			list = defaults
+		}
+
+		values := []string{}
+
+		for _, v := range list {
+			values = append(values, strings.ToLower(v))
+		}
+
+		sort.Strings(values)
+
+		return strings.Join(values, ",")
+	}
+
+	headers := []string{}
+
+	for name, values := range r.Headers {
+		headers = append(headers, strings.ToLower(name)+"="+normalize(values))
+	}
+
+	sort.Strings(headers)
+
+	paths := append([]string{}, r.Paths...)
+	sort.Strings(paths)
+
+	return strings.Join([]string{
+		normalize(r.Protocols, "http", "https"),
+		normalize(r.Hosts),
+		normalize(r.Methods),
+		strings.Join(headers, ";"),
+		strings.Join(paths, ","),
+	}, "|")
+}
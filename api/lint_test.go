@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestLintRoutesHTTPOnHTTPSService(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+services:
+  - name: billing
+    url: https://billing.internal
+    routes:
+      - name: defaulted
+        paths: [/billing]
+      - name: https-only
+        paths: [/secure]
+        protocols: [https]
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := LintRoutes(config)
+
+	// Routes without protocols accept http, as Kong defaults them to http and https
+	if len(issues) != 1 || issues[0].Rule != RuleHTTPOnHTTPSService || issues[0].Name != "defaulted" {
+		t.Fatalf("expected an http-on-https-service issue for route defaulted, got %+v", issues)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+)
+
+// LockConsumer is the username of the consumer marking that an apply is running.
+// It is left out of snapshots, so it is never diffed, dumped or deleted by an apply
+const LockConsumer string = "kongfig-lock"
+
+const defaultLockTimeout = 30 * time.Minute
+
+// LockInfo describes who holds the apply lock, stored as the custom_id of LockConsumer
+type LockInfo struct {
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Lock is the apply lock held by a client
+type Lock struct {
+	LockInfo
+
+	id     string
+	client *Client
+}
+
+// lockOwner identifies the current process, eg: deploy@ci-runner-3 (pid 42)
+func lockOwner() string {
+	name := "unknown"
+
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+// AcquireLock creates LockConsumer in Kong, failing when another apply holds
+// it. Locks expire after LockTimeout, an expired lock is taken over and
+// ForceUnlock takes over any lock
+func (c *Client) AcquireLock() (*Lock, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now().UTC()
+		l := &Lock{
+			LockInfo: LockInfo{Owner: c.LockOwner, AcquiredAt: now, ExpiresAt: now.Add(c.LockTimeout)},
+			client:   c,
+		}
+
+		info, _ := json.Marshal(l.LockInfo)
+		payload, _ := json.Marshal(map[string]string{"username": LockConsumer, "custom_id": string(info)})
+		created := Entity{}
+		res, err := c.httpRequest(http.MethodPost, c.BaseURL+"/consumers", payload, &created)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusCreated {
+			l.id = created.ID()
+			c.Logger.Debugf("Acquired the apply lock of %s until %s", c.BaseURL, l.ExpiresAt.Format(time.RFC3339))
+
+			return l, nil
+		}
+
+		if res.StatusCode != http.StatusConflict {
+			return nil, fmt.Errorf("[HTTP %d] Error acquiring the apply lock. Bad response from the API", res.StatusCode)
+		}
+
+		held, id, err := c.lockHolder()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case id == "":
+			// Released in the meantime
+			continue
+		case c.ForceUnlock:
+			c.Logger.Infof("Removing the apply lock held by %s since %s", held.Owner, held.AcquiredAt.Format(time.RFC3339))
+		case now.After(held.ExpiresAt):
+			c.Logger.Infof("Removing the apply lock held by %s, expired since %s", held.Owner, held.ExpiresAt.Format(time.RFC3339))
+		default:
+			return nil, fmt.Errorf("Kong at %s is locked by another apply: held by %s since %s, expiring at %s. Wait for it to finish, or remove the lock with --force-unlock if that apply died", c.BaseURL, held.Owner, held.AcquiredAt.Format(time.RFC3339), held.ExpiresAt.Format(time.RFC3339))
+		}
+
+		if err := c.deleteLock(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("Error acquiring the apply lock of %s, it was taken again meanwhile", c.BaseURL)
+}
+
+// lockHolder fetches LockConsumer, the returned ID is empty when nobody holds the lock.
+// A lock with unreadable info is reported as held by an unknown owner, expired at once
+func (c *Client) lockHolder() (LockInfo, string, error) {
+	info := LockInfo{Owner: "unknown"}
+	consumer := Entity{}
+	res, err := c.httpRequest(http.MethodGet, c.BaseURL+"/consumers/"+LockConsumer, nil, &consumer)
+
+	if err != nil {
+		return info, "", err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return info, "", nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return info, "", fmt.Errorf("[HTTP %d] Error fetching the apply lock. Bad response from the API", res.StatusCode)
+	}
+
+	if err := json.Unmarshal([]byte(stringField(consumer, "custom_id")), &info); err != nil {
+		c.Logger.Errorf("Error parsing the apply lock of %s: %s", c.BaseURL, err)
+	}
+
+	return info, consumer.ID(), nil
+}
+
+func (c *Client) deleteLock(id string) error {
+	res, err := c.httpRequest(http.MethodDelete, c.BaseURL+"/consumers/"+id, nil, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("[HTTP %d] Error removing the apply lock. Bad response from the API", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Release deletes the lock from Kong, logging how to remove it when that fails
+func (l *Lock) Release() {
+	c := l.client
+
+	if err := c.deleteLock(l.id); err != nil {
+		c.Logger.Errorf("Error releasing the apply lock of %s, remove it with --force-unlock: %s", c.BaseURL, err)
+		return
+	}
+
+	c.Logger.Debugf("Released the apply lock of %s", c.BaseURL)
+}
+
+// withoutLock removes LockConsumer from a list of consumers
+func withoutLock(consumers []Entity) []Entity {
+	kept := consumers[:0]
+
+	for _, e := range consumers {
+		if stringField(e, "username") != LockConsumer {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Level controls which messages a Logger writes
+type Level int
+
+// Log levels, from quietest to most verbose
+const (
+	LevelError Level = iota
+	LevelInfo
+	LevelDebug
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// Attribute names, or suffixes of names, holding secrets in credentials and plugin configs
+	sensitiveNames    = []string{"key", "secret", "password", "token"}
+	sensitiveSuffixes = []string{"_key", "_secret", "_password", "_token"}
+)
+
+// Logger receives diagnostic messages from the client. It can be replaced to
+// embed kongfig in other programs
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NewLogger returns a Logger writing messages up to the given level to w
+func NewLogger(w io.Writer, level Level) Logger {
+	return &stdLogger{logger: log.New(w, "", log.LstdFlags), level: level}
+}
+
+// NopLogger returns a Logger discarding every message
+func NopLogger() Logger {
+	return NewLogger(ioutil.Discard, LevelError)
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	level  Level
+}
+
+func (l *stdLogger) logf(level Level, prefix, format string, args ...interface{}) {
+	if level <= l.level {
+		l.logger.Printf(prefix+format, args...)
+	}
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, "DEBUG ", format, args...)
+}
+
+func (l *stdLogger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, "INFO ", format, args...)
+}
+
+func (l *stdLogger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, "ERROR ", format, args...)
+}
+
+// prefixLogger prefixes every message of another Logger
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+// PrefixLogger returns a Logger prefixing every message written to l, eg: with the name of a cluster
+func PrefixLogger(l Logger, prefix string) Logger {
+	return &prefixLogger{logger: l, prefix: strings.Replace(prefix, "%", "%%", -1)}
+}
+
+func (l *prefixLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.prefix+format, args...)
+}
+
+func (l *prefixLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.prefix+format, args...)
+}
+
+func (l *prefixLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.prefix+format, args...)
+}
+
+// WrapTransport replaces the transport used to reach the Admin API with the
+// result of wrap, which receives the current one
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	base := c.client.Transport
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c.client.Transport = wrap(base)
+}
+
+// TraceHTTP logs every request made to the Admin API and its response
+func (c *Client) TraceHTTP() {
+	c.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return NewTracingTransport(base, c.Logger)
+	})
+}
+
+// tracingTransport logs method, URL, status, latency and bodies of every
+// request, masking secrets
+type tracingTransport struct {
+	base   http.RoundTripper
+	logger Logger
+}
+
+// NewTracingTransport returns a RoundTripper logging every request made through base
+func NewTracingTransport(base http.RoundTripper, logger Logger) http.RoundTripper {
+	return &tracingTransport{base: base, logger: logger}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+
+	if req.Body != nil {
+		reqBody, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	latency := time.Since(start)
+
+	t.logger.Infof("--> %s %s %s", req.Method, req.URL, redactBody(reqBody))
+
+	if err != nil {
+		t.logger.Infof("<-- %s %s failed after %s: %s", req.Method, req.URL, latency, err)
+		return res, err
+	}
+
+	resBody, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	t.logger.Infof("<-- %s %s [HTTP %d] in %s %s", req.Method, req.URL, res.StatusCode, latency, redactBody(resBody))
+
+	return res, nil
+}
+
+// redactBody returns a JSON body with the values of sensitive attributes masked
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+
+	data, _ := json.Marshal(redact(v))
+
+	return string(data)
+}
+
+// redact masks the values of sensitive attributes at any depth
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, nested := range value {
+			if isSensitive(k) && nested != nil {
+				value[k] = redacted
+			} else {
+				value[k] = redact(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = redact(nested)
+		}
+	}
+
+	return v
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, s := range sensitiveNames {
+		if name == s {
+			return true
+		}
+	}
+
+	for _, s := range sensitiveSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package api
+
+import "fmt"
+
+// flattenNested moves the routes and plugins nested under services, and the
+// plugins nested under routes, to the top-level lists of the config, so both
+// syntaxes can be mixed
+func flattenNested(config *Config) error {
+	routes := []Route{}
+	plugins := []Plugin{}
+
+	for i := range config.Services {
+		s := &config.Services[i]
+
+		for _, r := range s.Routes {
+			if r.Service != "" && r.Service != s.Name {
+				return fmt.Errorf("Route %s is nested under service %s but applies to %s", r.Name, s.Name, r.Service)
+			}
+
+			r.Service = s.Name
+			routes = append(routes, r)
+		}
+
+		for _, p := range s.Plugins {
+			if err := nestedPlugin(p, KindService, s.Name); err != nil {
+				return err
+			}
+
+			p.Services = []string{s.Name}
+			plugins = append(plugins, p)
+		}
+
+		s.Routes, s.Plugins = nil, nil
+	}
+
+	config.Routes = append(config.Routes, routes...)
+
+	for i := range config.Routes {
+		r := &config.Routes[i]
+
+		if len(r.Plugins) > 0 && r.Name == "" {
+			return fmt.Errorf("Route of service %s with paths %v has nested plugins, it needs a name", r.Service, r.Paths)
+		}
+
+		for _, p := range r.Plugins {
+			if err := nestedPlugin(p, KindRoute, r.Name); err != nil {
+				return err
+			}
+
+			p.Routes = []string{r.Name}
+			plugins = append(plugins, p)
+		}
+
+		r.Plugins = nil
+	}
+
+	config.Plugins = append(config.Plugins, plugins...)
+
+	return nil
+}
+
+// nestedPlugin checks a plugin nested under an entity doesn't also apply to other ones
+func nestedPlugin(p Plugin, kind, name string) error {
+	if len(p.Services) > 0 || len(p.Routes) > 0 || p.Target != "" {
+		return fmt.Errorf("Plugin %s is nested under %s %s, it can't list services, routes or a target", pluginLabel(p), kind, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,500 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/mikefarah/yaml.v2"
+)
+
+const (
+	kongPluginExtension          = "x-kong-plugin-"
+	kongNameExtension            = "x-kong-name"
+	kongServiceDefaultsExtension = "x-kong-service-defaults"
+	kongRouteDefaultsExtension   = "x-kong-route-defaults"
+)
+
+var (
+	// HTTP methods of an OpenAPI path item, in the order they are grouped into routes
+	openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+	pathParam    = regexp.MustCompile(`\{([^}/]+)\}`)
+	invalidName  = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+	invalidGroup = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// OpenAPIOptions controls how an OpenAPI specification is imported
+type OpenAPIOptions struct {
+	// Service is the name of the generated service, defaults to x-kong-name or the title of the spec
+	Service string
+	// URL of the upstream, defaults to the first server of the spec
+	URL string
+}
+
+// FromOpenAPI generates a service, its routes and plugins from an OpenAPI 3 specification.
+// Paths are converted to anchored Kong regex paths, operations of a path are
+// grouped into a single route unless they declare different plugins, and
+// x-kong-plugin-<name> extensions become plugins of the service or routes
+func FromOpenAPI(data []byte, opts OpenAPIOptions) (*Config, error) {
+	spec := map[string]interface{}{}
+
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	if version, _ := spec["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("Only OpenAPI 3 specifications are supported")
+	}
+
+	s := Service{Name: opts.Service, URL: opts.URL}
+
+	if s.Name == "" {
+		s.Name, _ = spec[kongNameExtension].(string)
+	}
+
+	if s.Name == "" {
+		info, _ := spec["info"].(map[string]interface{})
+		title, _ := info["title"].(string)
+		s.Name = slug(title)
+	}
+
+	if s.Name == "" {
+		return nil, fmt.Errorf("The service name can't be inferred from the spec, please provide one")
+	}
+
+	if s.URL == "" {
+		servers := entityList(spec["servers"])
+
+		if len(servers) > 0 {
+			s.URL = stringField(servers[0], "url")
+		}
+	}
+
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		return nil, fmt.Errorf("The spec has no absolute server URL (%q), please provide the upstream URL", s.URL)
+	}
+
+	if defaults, ok := spec[kongServiceDefaultsExtension].(map[string]interface{}); ok {
+		fromEntity(defaults, &s)
+	}
+
+	config := &Config{Services: []Service{s}}
+	config.Plugins = append(config.Plugins, pluginsFor(pluginExtensions(spec), s.Name, "")...)
+
+	routeDefaults, _ := spec[kongRouteDefaultsExtension].(map[string]interface{})
+	paths, _ := spec["paths"].(map[string]interface{})
+
+	for _, path := range sortedAttributes(paths) {
+		item, _ := paths[path].(map[string]interface{})
+		pathPlugins := pluginExtensions(item)
+
+		name, _ := item[kongNameExtension].(string)
+
+		if name == "" {
+			name = slug(path)
+		}
+
+		for _, group := range groupOperations(item) {
+			r := Route{
+				Name:          fmt.Sprintf("%s-%s", s.Name, name),
+				Service:       s.Name,
+				Paths:         []string{kongPath(path)},
+				Methods:       group.methods,
+				RegexPriority: literalSegments(path),
+				// Anchored paths match the whole request path, which must not be stripped
+				StripPath: false,
+			}
+
+			if len(group.methods) < len(operations(item)) {
+				r.Name = fmt.Sprintf("%s-%s", r.Name, strings.ToLower(strings.Join(group.methods, "-")))
+			}
+
+			if routeDefaults != nil {
+				fromEntity(routeDefaults, &r)
+			}
+
+			config.Routes = append(config.Routes, r)
+			config.Plugins = append(config.Plugins, pluginsFor(mergePlugins(pathPlugins, group.plugins), "", r.Name)...)
+		}
+	}
+
+	return config, nil
+}
+
+// operationGroup represents the operations of a path sharing the same plugins
+type operationGroup struct {
+	methods []string
+	plugins map[string]interface{}
+}
+
+func operations(item map[string]interface{}) []string {
+	methods := []string{}
+
+	for _, method := range openAPIMethods {
+		if _, ok := item[method].(map[string]interface{}); ok {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// groupOperations groups the operations of a path item by their plugin extensions
+func groupOperations(item map[string]interface{}) []operationGroup {
+	groups := []operationGroup{}
+
+	for _, method := range operations(item) {
+		plugins := pluginExtensions(item[method].(map[string]interface{}))
+		found := false
+
+		for i := range groups {
+			if equalValues(toEntity(groups[i].plugins), toEntity(plugins)) {
+				groups[i].methods = append(groups[i].methods, strings.ToUpper(method))
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			groups = append(groups, operationGroup{methods: []string{strings.ToUpper(method)}, plugins: plugins})
+		}
+	}
+
+	return groups
+}
+
+// pluginExtensions returns the x-kong-plugin-<name> extensions of an object, keyed by plugin name
+func pluginExtensions(object map[string]interface{}) map[string]interface{} {
+	plugins := map[string]interface{}{}
+
+	for k, v := range object {
+		if strings.HasPrefix(k, kongPluginExtension) {
+			plugins[strings.TrimPrefix(k, kongPluginExtension)] = v
+		}
+	}
+
+	return plugins
+}
+
+// mergePlugins overrides the plugins of a path with the ones of an operation
+func mergePlugins(path, operation map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+
+	for k, v := range path {
+		merged[k] = v
+	}
+
+	for k, v := range operation {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// pluginsFor converts plugin extensions into plugins applied to a service or route.
+// An extension holds either the plugin config, or an object with name, enabled and config
+func pluginsFor(extensions map[string]interface{}, service, route string) []Plugin {
+	plugins := []Plugin{}
+
+	for _, name := range sortedAttributes(extensions) {
+		p := Plugin{Name: name}
+		ext, _ := extensions[name].(map[string]interface{})
+
+		if config, ok := ext["config"].(map[string]interface{}); ok {
+			fromEntity(ext, &p)
+			p.Config = config
+		} else {
+			p.Config = ext
+		}
+
+		if service != "" {
+			p.Services = []string{service}
+		}
+
+		if route != "" {
+			p.Routes = []string{route}
+		}
+
+		plugins = append(plugins, p)
+	}
+
+	return plugins
+}
+
+// kongPath converts an OpenAPI path into an anchored Kong regex path, where
+// every path parameter becomes a named capture group and the rest of the path
+// is matched literally
+func kongPath(path string) string {
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range pathParam.FindAllStringIndex(path, -1) {
+		name := invalidGroup.ReplaceAllString(strings.Trim(path[loc[0]:loc[1]], "{}"), "_")
+
+		b.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		fmt.Fprintf(&b, "(?<%s>[^/]+)", name)
+		last = loc[1]
+	}
+
+	b.WriteString(regexp.QuoteMeta(path[last:]))
+
+	return b.String() + "$"
+}
+
+// literalSegments counts the path segments without parameters, so routes with
+// more literal segments take precedence over routes matching the same requests
+func literalSegments(path string) int {
+	count := 0
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" && !pathParam.MatchString(segment) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// slug converts a free form string into a name usable for Kong entities
+func slug(s string) string {
+	return strings.Trim(strings.ToLower(invalidName.ReplaceAllString(s, "-")), "-")
+}
+
+// MergeConfig adds the services, routes and plugins of src, a parsed config,
+// into dst, a config as written. Entities with the same name are replaced
+// where dst defines them, nested or not, plugins being replaced for the
+// services and routes src applies them to
+func MergeConfig(dst, src *Config) {
+	for _, s := range src.Services {
+		replaced := false
+
+		for i := range dst.Services {
+			if dst.Services[i].Name == s.Name {
+				s.Routes, s.Plugins = dst.Services[i].Routes, dst.Services[i].Plugins
+				dst.Services[i], replaced = s, true
+			}
+		}
+
+		if !replaced {
+			dst.Services = append(dst.Services, s)
+		}
+	}
+
+	for _, r := range src.Routes {
+		if replaceRoute(dst, r) {
+			continue
+		}
+
+		// Routes are nested under their service when it nests routes already
+		if s := findService(dst, r.Service); s != nil && len(s.Routes) > 0 {
+			r.Service = ""
+			s.Routes = append(s.Routes, r)
+			continue
+		}
+
+		dst.Routes = append(dst.Routes, r)
+	}
+
+	templates := make(map[string]PluginTemplate)
+
+	for _, t := range dst.PluginTemplates {
+		templates[t.Name] = t
+	}
+
+	nested := nestedPlugins(dst)
+	nesting := make(map[string]bool)
+
+	for key, list := range nested {
+		nesting[key] = len(*list) > 0
+		kind, name := splitKey(key)
+		kept := []Plugin{}
+
+		for _, p := range *list {
+			if !replacedPlugin(src, rawPluginName(p, templates), kind, name) {
+				kept = append(kept, p)
+			}
+		}
+
+		*list = kept
+	}
+
+	plugins := []Plugin{}
+
+	for _, p := range dst.Plugins {
+		targets := len(p.Services) + len(p.Routes)
+
+		for _, replacement := range src.Plugins {
+			if replacement.Name == rawPluginName(p, templates) {
+				p.Services = without(p.Services, replacement.Services)
+				p.Routes = without(p.Routes, replacement.Routes)
+			}
+		}
+
+		// Drop plugins whose services and routes were all replaced
+		if targets == 0 || len(p.Services)+len(p.Routes) > 0 {
+			plugins = append(plugins, p)
+		}
+	}
+
+	// Plugins applied to a single service or route are nested under it when it nests plugins already
+	for _, p := range src.Plugins {
+		key := ""
+
+		switch {
+		case len(p.Services) == 1 && len(p.Routes) == 0:
+			key = KindService + "\x00" + p.Services[0]
+		case len(p.Services) == 0 && len(p.Routes) == 1:
+			key = KindRoute + "\x00" + p.Routes[0]
+		}
+
+		if list, ok := nested[key]; ok && nesting[key] {
+			p.Services, p.Routes, p.Target = nil, nil, ""
+			*list = append(*list, p)
+			continue
+		}
+
+		plugins = append(plugins, p)
+	}
+
+	dst.Plugins = plugins
+}
+
+// replaceRoute replaces the route of dst with the same name as r, at the top
+// level or nested under its service, keeping its nested plugins
+func replaceRoute(dst *Config, r Route) bool {
+	if r.Name == "" {
+		return false
+	}
+
+	for i := range dst.Routes {
+		if dst.Routes[i].Name == r.Name {
+			r.Plugins = dst.Routes[i].Plugins
+			dst.Routes[i] = r
+
+			return true
+		}
+	}
+
+	for i := range dst.Services {
+		s := &dst.Services[i]
+
+		for j := range s.Routes {
+			if s.Routes[j].Name != r.Name {
+				continue
+			}
+
+			r.Plugins = s.Routes[j].Plugins
+
+			// Routes moving to another service leave the nested ones
+			if r.Service != s.Name {
+				s.Routes = append(s.Routes[:j], s.Routes[j+1:]...)
+				dst.Routes = append(dst.Routes, r)
+
+				return true
+			}
+
+			r.Service = ""
+			s.Routes[j] = r
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func findService(config *Config, name string) *Service {
+	for i := range config.Services {
+		if config.Services[i].Name == name {
+			return &config.Services[i]
+		}
+	}
+
+	return nil
+}
+
+// nestedPlugins returns the plugins nested under the services and named
+// routes of a config as written, keyed by kind and name
+func nestedPlugins(config *Config) map[string]*[]Plugin {
+	nested := make(map[string]*[]Plugin)
+
+	for i := range config.Services {
+		s := &config.Services[i]
+		nested[KindService+"\x00"+s.Name] = &s.Plugins
+
+		for j := range s.Routes {
+			if r := &s.Routes[j]; r.Name != "" {
+				nested[KindRoute+"\x00"+r.Name] = &r.Plugins
+			}
+		}
+	}
+
+	for i := range config.Routes {
+		if r := &config.Routes[i]; r.Name != "" {
+			nested[KindRoute+"\x00"+r.Name] = &r.Plugins
+		}
+	}
+
+	return nested
+}
+
+func splitKey(key string) (string, string) {
+	parts := strings.SplitN(key, "\x00", 2)
+
+	return parts[0], parts[1]
+}
+
+// replacedPlugin tells whether src applies a plugin named name to the service or route
+func replacedPlugin(src *Config, name, kind, target string) bool {
+	for _, p := range src.Plugins {
+		targets := p.Services
+
+		if kind == KindRoute {
+			targets = p.Routes
+		}
+
+		for _, t := range targets {
+			if p.Name == name && t == target {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// rawPluginName returns the name of a plugin of a config as written, which
+// plugins extending a template may get from it
+func rawPluginName(p Plugin, templates map[string]PluginTemplate) string {
+	if p.Name != "" || p.Extends == "" {
+		return p.Name
+	}
+
+	t, err := resolveTemplate(templates, p.Extends, nil)
+
+	if err != nil {
+		return ""
+	}
+
+	return t.Plugin
+}
+
+// without returns the items of list not present in remove
+func without(list, remove []string) []string {
+	var result []string
+
+	for _, item := range list {
+		found := false
+
+		for _, r := range remove {
+			found = found || r == item
+		}
+
+		if !found {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
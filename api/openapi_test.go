@@ -0,0 +1,65 @@
+package api
+
+import "testing"
+
+func TestMergeConfigKeepsLayout(t *testing.T) {
+	dst, err := ParseRawConfig([]byte(`
+plugin_templates:
+  - name: standard-rate-limiting
+    plugin: rate-limiting
+    config: {minute: 100}
+services:
+  - name: users
+    url: http://old.internal
+    routes:
+      - name: legacy
+        paths: [/legacy]
+    plugins:
+      - extends: standard-rate-limiting
+      - name: correlation-id
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ParseConfig([]byte(`
+services:
+  - name: users
+    url: http://users.internal
+routes:
+  - name: users-list
+    apply_to: users
+    paths: [/users$]
+plugins:
+  - name: rate-limiting
+    services: [users]
+    config: {minute: 5}
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MergeConfig(dst, src)
+
+	if len(dst.Services) != 1 || dst.Services[0].URL != "http://users.internal" {
+		t.Fatalf("expected the users service to be replaced, got %+v", dst.Services)
+	}
+
+	routes := dst.Services[0].Routes
+
+	if len(dst.Routes) != 0 || len(routes) != 2 || routes[0].Name != "legacy" || routes[1].Name != "users-list" || routes[1].Service != "" {
+		t.Errorf("expected the generated route to be nested after legacy, got %+v and %+v", routes, dst.Routes)
+	}
+
+	plugins := dst.Services[0].Plugins
+
+	if len(dst.Plugins) != 0 || len(plugins) != 2 || plugins[0].Name != "correlation-id" || plugins[1].Name != "rate-limiting" || plugins[1].Config["minute"] != 5 {
+		t.Errorf("expected the plugin extending the template to be replaced in place, got %+v and %+v", plugins, dst.Plugins)
+	}
+
+	if len(dst.PluginTemplates) != 1 {
+		t.Errorf("expected the plugin templates to be kept, got %+v", dst.PluginTemplates)
+	}
+}
@@ -0,0 +1,500 @@
+package api
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Progress of an operation recorded in a journal
+const (
+	OperationPending string = "pending"
+	OperationStarted string = "started"
+	OperationDone    string = "done"
+)
+
+// Operation is a single change of an apply, computed before anything is changed
+type Operation struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// Request made to the Admin API, unchanged entities make none
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Body   Entity `json:"body,omitempty"`
+	Status string `json:"status"`
+	// Fields lists the resolved attributes of plugins extending a template
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// Plan is the ordered list of operations bringing Kong in sync with a config
+type Plan struct {
+	Host       string    `json:"host,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ConfigHash string    `json:"config_hash,omitempty"`
+	// LiveHash identifies the state of Kong the plan was computed from
+	LiveHash   string      `json:"live_hash,omitempty"`
+	Operations []Operation `json:"operations"`
+}
+
+// LoadPlan reads a plan written by Save
+func LoadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("Error parsing plan %s: %s", path, err)
+	}
+
+	return p, nil
+}
+
+// Save writes the plan to path. Plans contain the credentials of the config,
+// they are only readable by their owner
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Changes returns the number of operations changing Kong
+func (p *Plan) Changes() int {
+	changes := 0
+
+	for _, op := range p.Operations {
+		if op.Action != ActionNone {
+			changes++
+		}
+	}
+
+	return changes
+}
+
+// WriteText writes the operations of the plan changing Kong in order, followed by a summary
+func (p *Plan) WriteText(w io.Writer) {
+	if p.Changes() == 0 {
+		fmt.Fprintln(w, "No changes, Kong is in sync with the config")
+		return
+	}
+
+	removed := make(map[string]bool)
+
+	for _, op := range p.Removals() {
+		removed[op.Kind+"\x00"+op.Name] = true
+	}
+
+	counts := make(map[string]int)
+
+	for _, op := range p.Operations {
+		symbol := "~"
+
+		switch op.Action {
+		case ActionNone:
+			continue
+		case ActionCreate:
+			symbol = "+"
+		case ActionDelete:
+			symbol = "-"
+		}
+
+		counts[symbol]++
+		line := fmt.Sprintf("%s %s %s %s", symbol, op.Action, op.Kind, op.Name)
+
+		if removed[op.Kind+"\x00"+op.Name] {
+			line += " (missing from the config)"
+		}
+
+		fmt.Fprintln(w, line)
+
+		for _, f := range op.Fields {
+			if op.Action == ActionCreate {
+				fmt.Fprintf(w, "    %s: %s\n", f.Field, jsonString(f.New))
+				continue
+			}
+
+			fmt.Fprintf(w, "    %s: %s => %s\n", f.Field, jsonString(f.Old), jsonString(f.New))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d operation(s): %d to create, %d to update, %d to delete (%d missing from the config)\n", p.Changes(), counts["+"], counts["~"], counts["-"], len(removed))
+}
+
+func (p *Plan) add(kind, name, action, method, path string, body Entity) *Operation {
+	p.Operations = append(p.Operations, Operation{
+		Kind:   kind,
+		Name:   name,
+		Action: action,
+		Method: method,
+		Path:   path,
+		Body:   body,
+		Status: OperationPending,
+	})
+
+	return &p.Operations[len(p.Operations)-1]
+}
+
+// ComputePlan returns the operations applying a config to Kong in the state of
+// a snapshot: consumers are recreated along with their credentials, stale
+// plugins, routes and services are deleted, then services, routes and plugins
+// are upserted in place. Routes moving to another service are recreated, and
+// plugins deleted by Kong along with them are created again rather than
+// updated. Plugins applied to consumers are not managed by kongfig
+func ComputePlan(config *Config, s *Snapshot) *Plan {
+	p := &Plan{}
+	live := newLiveIndex(s)
+	deleted := make(map[string]bool)
+
+	// Deleting consumers deletes their credentials and plugins as well
+	if len(config.Credentials) > 0 {
+		for _, e := range s.Consumers {
+			p.add(KindConsumer, e.displayName(), ActionDelete, http.MethodDelete, "/consumers/"+e.ID(), nil)
+			deleted[e.ID()] = true
+		}
+	}
+
+	desired := make(map[string]bool)
+
+	for _, d := range desiredPlugins(config) {
+		desired[d.name] = true
+	}
+
+	services := make(map[string]keyedEntity)
+
+	for _, d := range desiredServices(config) {
+		services[d.name] = d
+	}
+
+	routes := make(map[string]keyedEntity)
+
+	for _, d := range desiredRoutes(config) {
+		routes[d.name] = d
+	}
+
+	plugins := make(map[string]Entity)
+	stale := []string{}
+
+	for _, e := range s.Plugins {
+		// Plugins applied to consumers are not managed by kongfig
+		if foreignID(e, "consumer") != "" {
+			continue
+		}
+
+		scope := live.pluginScope(e)
+		plugins[scope] = e
+
+		if !desired[scope] {
+			stale = append(stale, scope)
+		}
+	}
+
+	sort.Strings(stale)
+
+	for _, scope := range stale {
+		p.add(KindPlugin, scope, ActionDelete, http.MethodDelete, "/plugins/"+plugins[scope].ID(), nil)
+		delete(plugins, scope)
+	}
+
+	// Routes are kept when they stay on the same service
+	liveRoutes := make(map[string]Entity)
+
+	for _, e := range s.Routes {
+		name := live.routeNames[e.ID()]
+
+		if d, ok := routes[name]; ok && d.fields["service"] == live.serviceNames[foreignID(e, "service")] {
+			liveRoutes[name] = e
+		}
+	}
+
+	for _, e := range s.Routes {
+		name := live.routeNames[e.ID()]
+
+		if kept, ok := liveRoutes[name]; !ok || kept.ID() != e.ID() {
+			p.add(KindRoute, name, ActionDelete, http.MethodDelete, "/routes/"+e.ID(), nil)
+			deleted[e.ID()] = true
+		}
+	}
+
+	liveServices := make(map[string]Entity)
+
+	for _, e := range s.Services {
+		name := stringField(e, "name")
+
+		if _, ok := services[name]; ok {
+			liveServices[name] = e
+			continue
+		}
+
+		p.add(KindService, e.displayName(), ActionDelete, http.MethodDelete, "/services/"+e.ID(), nil)
+		deleted[e.ID()] = true
+	}
+
+	// Plugins of deleted routes and services are deleted along with them
+	for scope, e := range plugins {
+		if deleted[foreignID(e, "route")] || deleted[foreignID(e, "service")] {
+			delete(plugins, scope)
+		}
+	}
+
+	for _, svc := range config.Services {
+		d := services[svc.Name]
+		action := ActionCreate
+
+		if e, ok := liveServices[svc.Name]; ok {
+			fields := diffFields("", d.fields, e, d.defaults, false)
+
+			if len(fields) == 0 {
+				p.add(KindService, svc.Name, ActionNone, "", "", nil)
+				continue
+			}
+
+			action = updateAction(fields)
+		}
+
+		p.add(KindService, svc.Name, action, http.MethodPut, "/services/"+svc.Name, toEntity(svc))
+	}
+
+	for _, r := range config.Routes {
+		name := routeName(r.Name, r.Service, r.Paths)
+		e, ok := liveRoutes[name]
+
+		if !ok {
+			p.add(KindRoute, name, ActionCreate, http.MethodPost, fmt.Sprintf("/services/%s/routes", r.Service), toEntity(r))
+			continue
+		}
+
+		d := routes[name]
+		fields := diffFields("", d.fields, live.routeFields(e), d.defaults, false)
+
+		if len(fields) == 0 {
+			p.add(KindRoute, name, ActionNone, "", "", nil)
+			continue
+		}
+
+		// Routes are replaced as a whole, so attributes removed from the config are reset
+		body := toEntity(r)
+		delete(body, "id")
+		body["service"] = map[string]interface{}{"id": foreignID(e, "service")}
+		p.add(KindRoute, name, updateAction(fields), http.MethodPut, "/routes/"+e.ID(), body)
+	}
+
+	for _, plugin := range config.Plugins {
+		if plugin.Target == "global" {
+			p.upsertPlugin(plugin, "/plugins", pluginName(plugin.Name, "", ""), plugins)
+			continue
+		}
+
+		for _, service := range plugin.Services {
+			p.upsertPlugin(plugin, fmt.Sprintf("/services/%s/plugins", service), pluginName(plugin.Name, service, ""), plugins)
+		}
+
+		for _, route := range plugin.Routes {
+			p.upsertPlugin(plugin, fmt.Sprintf("/routes/%s/plugins", route), pluginName(plugin.Name, "", route), plugins)
+		}
+	}
+
+	if len(config.Credentials) > 0 {
+		for _, consumer := range config.Consumers {
+			p.add(KindConsumer, consumer.Username, ActionCreate, http.MethodPost, "/consumers", toEntity(consumer))
+		}
+
+		for _, cred := range config.Credentials {
+			name := credentialName(cred.Name, cred.Target, cred.Config[credentialKeys[cred.Name]])
+			p.add(KindCredential, name, ActionCreate, http.MethodPost, fmt.Sprintf("/consumers/%s/%s", cred.Target, cred.Name), toEntity(cred.Config))
+		}
+	}
+
+	return p
+}
+
+// Plan computes the operations applying the config would make, without
+// changing anything. The plan can be saved and applied later with ApplyPlan
+func (c *Client) Plan() (*Plan, error) {
+	if !c.DisableValidation {
+		if err := c.validateBeforeApply(); err != nil {
+			return nil, err
+		}
+	}
+
+	dbless, err := c.DBLess()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dbless {
+		return nil, fmt.Errorf("Kong runs in DB-less mode, where configs are loaded as a whole without a plan")
+	}
+
+	snapshot, err := c.TakeSnapshot()
+
+	if err != nil {
+		return nil, fmt.Errorf("Error taking snapshot: %s", err)
+	}
+
+	return c.plan(snapshot)
+}
+
+// ApplyPlan executes exactly the operations of a plan made with Plan, failing
+// when Kong changed since. Kong is snapshotted, locked and rolled back on
+// failure as with ApplyConfig
+func (c *Client) ApplyPlan(p *Plan) error {
+	c.Logger.Infof("Applying plan made at %s to %s", p.CreatedAt.Format(time.RFC3339), c.BaseURL)
+
+	if p.Host != c.BaseURL {
+		return fmt.Errorf("The plan was made for %s, not %s", p.Host, c.BaseURL)
+	}
+
+	if !c.DisableLock {
+		lock, err := c.AcquireLock()
+
+		if err != nil {
+			return err
+		}
+
+		defer lock.Release()
+	}
+
+	snapshot, err := c.TakeSnapshot()
+
+	if err != nil {
+		return fmt.Errorf("Error taking snapshot: %s", err)
+	}
+
+	if liveHash(snapshot) != p.LiveHash {
+		return fmt.Errorf("Kong changed since the plan was made at %s, make a new plan", p.CreatedAt.Format(time.RFC3339))
+	}
+
+	return c.applyPlan(p, snapshot)
+}
+
+// plan computes the operations applying the selected entities of the config
+// to Kong in the state of a snapshot, enforcing the deletion policy. The
+// entities missing from the config about to be deleted are logged
+func (c *Client) plan(s *Snapshot) (*Plan, error) {
+	config, live := c.Selector.Select(c.config, s)
+	plan := ComputePlan(config, c.DeletionPolicy.Keep(config, live))
+	plan.Host = c.BaseURL
+	plan.CreatedAt = time.Now().UTC()
+	plan.ConfigHash = configHash(c.config)
+	plan.LiveHash = liveHash(s)
+
+	if err := c.DeletionPolicy.Check(plan); err != nil {
+		return nil, err
+	}
+
+	if removals := plan.Removals(); len(removals) > 0 {
+		c.Logger.Infof("Entities missing from the config to delete (%d):", len(removals))
+
+		for _, op := range removals {
+			c.Logger.Infof("  - %s %s", op.Kind, op.Name)
+		}
+	}
+
+	return plan, nil
+}
+
+// upsertPlugin plans the creation of a plugin on the collection at path, or
+// the update of the live plugin with the same scope when its config differs.
+// The config a plugin gets from its template is listed with the operation
+func (p *Plan) upsertPlugin(plugin Plugin, path, name string, live map[string]Entity) {
+	existing, ok := live[name]
+	desired := pluginEntity(plugin)
+
+	if !ok {
+		op := p.add(KindPlugin, name, ActionCreate, http.MethodPost, path, toEntity(plugin))
+
+		if plugin.Extends != "" {
+			op.Fields = createdFields("", desired)
+		}
+
+		return
+	}
+
+	fields := diffFields("", desired, existing, nil, true)
+
+	if len(fields) == 0 {
+		p.add(KindPlugin, name, ActionNone, "", "", nil)
+		return
+	}
+
+	op := p.add(KindPlugin, name, updateAction(fields), http.MethodPatch, "/plugins/"+existing.ID(), desired)
+
+	if plugin.Extends != "" {
+		op.Fields = fields
+	}
+}
+
+// perform makes the request of an operation, deleting an entity that no longer exists succeeds
+func (c *Client) perform(op *Operation) (int, error) {
+	if op.Method == "" {
+		return 0, nil
+	}
+
+	var payload []byte
+
+	if op.Body != nil {
+		data, err := json.Marshal(op.Body)
+
+		if err != nil {
+			return 0, err
+		}
+
+		payload = data
+	}
+
+	body := map[string]interface{}{}
+	res, err := c.httpRequest(op.Method, c.BaseURL+op.Path, payload, &body)
+
+	if err != nil {
+		return 0, err
+	}
+
+	expected := map[string]int{
+		http.MethodPost:   http.StatusCreated,
+		http.MethodPut:    http.StatusOK,
+		http.MethodPatch:  http.StatusOK,
+		http.MethodDelete: http.StatusNoContent,
+	}
+
+	if res.StatusCode == expected[op.Method] || (op.Method == http.MethodDelete && res.StatusCode == http.StatusNotFound) {
+		return res.StatusCode, nil
+	}
+
+	if message, ok := body["message"].(string); ok {
+		return res.StatusCode, fmt.Errorf("[HTTP %d] Error %s %s %s: %s", res.StatusCode, actionVerbs[op.Action], op.Kind, op.Name, message)
+	}
+
+	return res.StatusCode, fmt.Errorf("[HTTP %d] Error %s %s %s. Bad response from the API", res.StatusCode, actionVerbs[op.Action], op.Kind, op.Name)
+}
+
+// Verbs describing actions in errors
+var actionVerbs = map[string]string{
+	ActionCreate:  "creating",
+	ActionUpdate:  "updating",
+	ActionEnable:  "enabling",
+	ActionDisable: "disabling",
+	ActionDelete:  "deleting",
+}
+
+// liveHash identifies the state of Kong in a snapshot, regardless of when it was taken
+func liveHash(s *Snapshot) string {
+	data, _ := json.Marshal([]interface{}{s.Services, s.Routes, s.Plugins, s.Consumers, s.Credentials})
+	sum := md5.Sum(data)
+
+	return hex.EncodeToString(sum[:])
+}
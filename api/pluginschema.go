@@ -0,0 +1,460 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Lint rules of plugin and credential configs
+const (
+	RuleUnknownPlugin string = "unknown-plugin"
+	RuleUnknownField  string = "unknown-field"
+	RuleTypeMismatch  string = "type-mismatch"
+	RuleMissingField  string = "missing-field"
+	RuleInvalidValue  string = "invalid-value"
+	// No schema is available for the Kong version, so no config was validated
+	RuleMissingSchemas string = "missing-schemas"
+)
+
+const defaultSchemaDir = ".kongfig/schemas"
+
+// SchemaSource provides the schemas of plugins and credentials, as returned by
+// the Admin API. Schema returns nil when the source has no schema for the
+// entity, and errNotFound when Kong doesn't know it
+type SchemaSource interface {
+	Schema(kind, name string) ([]byte, error)
+}
+
+// SchemaField describes an attribute of a plugin or credential config
+type SchemaField struct {
+	Name     string
+	Type     string
+	Required bool
+	// Default is set when Kong fills the attribute in when omitted
+	Default  bool
+	Auto     bool
+	OneOf    []interface{}
+	Fields   []SchemaField
+	Elements *SchemaField
+	Values   *SchemaField
+}
+
+// liveSchemas fetches schemas from the Admin API
+type liveSchemas struct {
+	c *Client
+}
+
+func (s *liveSchemas) Schema(kind, name string) ([]byte, error) {
+	path := "/plugins/schema/" + name
+
+	if kind == KindCredential {
+		path = "/schemas/" + declarativeCredentials[name]
+	}
+
+	body := json.RawMessage{}
+	res, err := s.c.httpRequest(http.MethodGet, s.c.rootURL()+path, nil, &body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[HTTP %d] Error fetching schema of %s %s. Bad response from the API", res.StatusCode, kind, name)
+	}
+
+	return body, nil
+}
+
+// SchemaCache stores the schemas of a Source in Dir, to validate configs
+// offline. Without a Source, only the stored schemas are used
+type SchemaCache struct {
+	Dir    string
+	Source SchemaSource
+}
+
+func (s *SchemaCache) Schema(kind, name string) ([]byte, error) {
+	path := filepath.Join(s.Dir, kind, name+".json")
+	data, err := ioutil.ReadFile(path)
+
+	if err == nil {
+		return data, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if s.Source == nil {
+		return nil, nil
+	}
+
+	data, err = s.Source.Schema(kind, name)
+
+	if err != nil || data == nil {
+		return data, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	return data, ioutil.WriteFile(path, data, 0644)
+}
+
+// Schemas returns the schemas of the live Kong, cached in SchemaDir per major
+// and minor Kong version as bundled schemas are
+func (c *Client) Schemas() (SchemaSource, error) {
+	version, err := c.KongVersion()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SchemaCache{Dir: SchemaVersionDir(c.SchemaDir, version), Source: &liveSchemas{c: c}}, nil
+}
+
+// ValidatePlugins validates the plugin and credential configs against the schemas of the live Kong
+func (c *Client) ValidatePlugins() ([]Issue, error) {
+	schemas, err := c.Schemas()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return LintPlugins(c.config, schemas)
+}
+
+// LintPlugins validates every plugin and credential config against its
+// schema, reporting unknown attributes, type mismatches and missing required
+// attributes. Entities without a schema in source are skipped
+func LintPlugins(config *Config, source SchemaSource) ([]Issue, error) {
+	issues := []Issue{}
+	schemas := make(map[string][]SchemaField)
+	unknown := make(map[string]bool)
+
+	fields := func(kind, name string) ([]SchemaField, bool, error) {
+		key := kind + "/" + name
+
+		if unknown[key] {
+			return nil, false, errNotFound
+		}
+
+		if f, ok := schemas[key]; ok {
+			return f, f != nil, nil
+		}
+
+		data, err := source.Schema(kind, name)
+
+		if err == errNotFound && kind == KindPlugin {
+			unknown[key] = true
+			return nil, false, err
+		}
+
+		if err != nil && err != errNotFound {
+			return nil, false, err
+		}
+
+		if data == nil {
+			schemas[key] = nil
+			return nil, false, nil
+		}
+
+		f, err := ParseSchema(kind, data)
+
+		if err != nil {
+			return nil, false, fmt.Errorf("Error parsing schema of %s %s: %s", kind, name, err)
+		}
+
+		schemas[key] = f
+
+		return f, true, nil
+	}
+
+	for _, p := range config.Plugins {
+		name := pluginName(p.Name, firstOrEmpty(p.Services), firstOrEmpty(p.Routes))
+		f, ok, err := fields(KindPlugin, p.Name)
+
+		if err == errNotFound {
+			issues = append(issues, Issue{Rule: RuleUnknownPlugin, Severity: SeverityError, Kind: KindPlugin, Name: name, Message: fmt.Sprintf("plugin %s is not installed in Kong", p.Name)})
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			validateRecord("config", f, p.Config, func(rule, message string) {
+				issues = append(issues, Issue{Rule: rule, Severity: SeverityError, Kind: KindPlugin, Name: name, Message: message})
+			})
+		}
+	}
+
+	for _, cred := range config.Credentials {
+		name := credentialName(cred.Name, cred.Target, cred.Config[credentialKeys[cred.Name]])
+		f, ok, err := fields(KindCredential, cred.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			validateRecord("config", f, cred.Config, func(rule, message string) {
+				issues = append(issues, Issue{Rule: rule, Severity: SeverityError, Kind: KindCredential, Name: name, Message: message})
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// ParseSchema returns the attributes of a plugin config or of a credential
+// from a schema returned by the Admin API, either in the format of Kong 1.x
+// or in the legacy one of Kong 0.x
+func ParseSchema(kind string, data []byte) ([]SchemaField, error) {
+	raw := map[string]interface{}{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Legacy plugin schemas only describe the plugin config
+	if legacy, ok := raw["fields"].(map[string]interface{}); ok {
+		return legacyFields(legacy), nil
+	}
+
+	fields := schemaFields(raw["fields"])
+
+	if kind == KindCredential {
+		return fields, nil
+	}
+
+	for _, f := range fields {
+		if f.Name == "config" {
+			return f.Fields, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the schema has no config attribute")
+}
+
+// schemaFields parses the list of single attribute objects of Kong 1.x schemas
+func schemaFields(v interface{}) []SchemaField {
+	fields := []SchemaField{}
+	list, _ := v.([]interface{})
+
+	for _, item := range list {
+		m, _ := item.(map[string]interface{})
+
+		for name, definition := range m {
+			d, _ := definition.(map[string]interface{})
+			fields = append(fields, schemaField(name, d))
+		}
+	}
+
+	return fields
+}
+
+func schemaField(name string, d map[string]interface{}) SchemaField {
+	f := SchemaField{Name: name}
+	f.Type, _ = d["type"].(string)
+	f.Required, _ = d["required"].(bool)
+	f.Auto, _ = d["auto"].(bool)
+	f.OneOf, _ = d["one_of"].([]interface{})
+	_, f.Default = d["default"]
+	f.Fields = schemaFields(d["fields"])
+
+	if elements, ok := d["elements"].(map[string]interface{}); ok {
+		e := schemaField("", elements)
+		f.Elements = &e
+	}
+
+	if values, ok := d["values"].(map[string]interface{}); ok {
+		v := schemaField("", values)
+		f.Values = &v
+	}
+
+	return f
+}
+
+// legacyFields parses the attributes of Kong 0.x schemas, keyed by name
+func legacyFields(m map[string]interface{}) []SchemaField {
+	fields := []SchemaField{}
+
+	for _, name := range sortedAttributes(m) {
+		d, _ := m[name].(map[string]interface{})
+		f := SchemaField{Name: name}
+		f.Type, _ = d["type"].(string)
+		f.Required, _ = d["required"].(bool)
+		f.OneOf, _ = d["enum"].([]interface{})
+		_, f.Default = d["default"]
+
+		if nested, ok := d["schema"].(map[string]interface{}); ok {
+			nestedFields, _ := nested["fields"].(map[string]interface{})
+			f.Type = "record"
+			f.Fields = legacyFields(nestedFields)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// validateRecord validates the attributes of value against fields
+func validateRecord(path string, fields []SchemaField, value map[string]interface{}, report func(rule, message string)) {
+	known := make(map[string]bool)
+
+	for _, f := range fields {
+		known[f.Name] = true
+		v, ok := value[f.Name]
+
+		if !ok || v == nil {
+			// Foreign keys and generated attributes are set by kongfig or Kong
+			if f.Required && !f.Default && !f.Auto && f.Type != "foreign" && !defaultedRecord(f) {
+				report(RuleMissingField, fmt.Sprintf("required attribute %s is missing", joinPath(path, f.Name)))
+			}
+
+			continue
+		}
+
+		validateValue(joinPath(path, f.Name), f, v, report)
+	}
+
+	for _, name := range sortedAttributes(value) {
+		if !known[name] {
+			report(RuleUnknownField, fmt.Sprintf("unknown attribute %s", joinPath(path, name)))
+		}
+	}
+}
+
+// defaultedRecord tells whether Kong fills a record in when it is omitted, as
+// every attribute of the record has a default, is optional or is such a record
+func defaultedRecord(f SchemaField) bool {
+	if f.Type != "record" {
+		return false
+	}
+
+	for _, nested := range f.Fields {
+		if nested.Required && !nested.Default && !nested.Auto && !defaultedRecord(nested) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateValue checks the type and allowed values of an attribute
+func validateValue(path string, f SchemaField, v interface{}, report func(rule, message string)) {
+	mismatch := func() {
+		report(RuleTypeMismatch, fmt.Sprintf("attribute %s must be of type %s, got %s", path, f.Type, jsonString(v)))
+	}
+
+	switch f.Type {
+	case "string", "url":
+		if _, ok := v.(string); !ok {
+			mismatch()
+			return
+		}
+	case "number", "timestamp":
+		if _, ok := toFloat(v); !ok {
+			mismatch()
+			return
+		}
+	case "integer":
+		if n, ok := toFloat(v); !ok || n != float64(int64(n)) {
+			mismatch()
+			return
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			mismatch()
+			return
+		}
+	case "array", "set":
+		list, ok := v.([]interface{})
+
+		if !ok {
+			// Kong 0.x accepts comma separated strings for arrays
+			if _, isString := v.(string); !isString || f.Elements != nil {
+				mismatch()
+			}
+
+			return
+		}
+
+		if f.Elements != nil {
+			for i, item := range list {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), *f.Elements, item, report)
+			}
+		}
+	case "map":
+		m, ok := v.(map[string]interface{})
+
+		if !ok {
+			mismatch()
+			return
+		}
+
+		if f.Values != nil {
+			for _, k := range sortedAttributes(m) {
+				validateValue(joinPath(path, k), *f.Values, m[k], report)
+			}
+		}
+	case "record", "table":
+		m, ok := v.(map[string]interface{})
+
+		if !ok {
+			mismatch()
+			return
+		}
+
+		validateRecord(path, f.Fields, m, report)
+	}
+
+	if len(f.OneOf) > 0 && !oneOf(f.OneOf, v) {
+		report(RuleInvalidValue, fmt.Sprintf("attribute %s must be one of %s, got %s", path, jsonString(f.OneOf), jsonString(v)))
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+func oneOf(allowed []interface{}, v interface{}) bool {
+	for _, a := range allowed {
+		if reflect.DeepEqual(a, v) || fmt.Sprint(a) == fmt.Sprint(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func firstOrEmpty(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+
+	return list[0]
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintPlugin(t *testing.T, plugin string) []Issue {
+	t.Helper()
+
+	config, err := ParseConfig([]byte(plugin))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := LintPlugins(config, BundledSchemas("1.1.0"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return issues
+}
+
+func TestLintPluginsDefaultedRecords(t *testing.T) {
+	issues := lintPlugin(t, `
+plugins:
+  - name: request-transformer
+    target: global
+    config:
+      add:
+        headers: ["x-source:kongfig"]
+`)
+
+	for _, i := range issues {
+		t.Errorf("unexpected issue: %s", i.Message)
+	}
+}
+
+func TestLintPluginsIssues(t *testing.T) {
+	issues := lintPlugin(t, `
+plugins:
+  - name: rate-limiting
+    target: global
+    config:
+      minute: 10
+      unknown: true
+`)
+
+	if len(issues) != 1 || issues[0].Rule != RuleUnknownField || !strings.Contains(issues[0].Message, "config.unknown") {
+		t.Fatalf("expected an unknown attribute issue, got %+v", issues)
+	}
+
+	issues = lintPlugin(t, `
+plugins:
+  - name: request-transformer
+    target: global
+    config:
+      add:
+        headers: "x-source"
+`)
+
+	if len(issues) != 1 || issues[0].Rule != RuleTypeMismatch {
+		t.Fatalf("expected a type mismatch issue, got %+v", issues)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DeletionPolicy controls which entities of Kong missing from the config an
+// apply deletes. Entities kongfig recreates are not concerned
+type DeletionPolicy struct {
+	// NoPrune keeps every entity missing from the config, only creating and updating entities
+	NoPrune bool
+	// Protect lists patterns of entities never deleted, as kind:glob or glob
+	// matching entities of every kind, eg: service:legacy-* or admin-*
+	Protect []string
+	// AllowDelete is the most entities an apply may delete, any number when negative
+	AllowDelete int
+}
+
+// NewDeletionPolicy returns a deletion policy, checking its protection patterns
+func NewDeletionPolicy(prune bool, protect []string, allowDelete int) (*DeletionPolicy, error) {
+	for _, pattern := range protect {
+		kind, glob := splitProtectPattern(pattern)
+
+		if _, ok := kindOrder[kind]; kind != "" && !ok {
+			return nil, fmt.Errorf("Unknown entity type in protection pattern %s, expected one of: service, route, plugin, consumer", pattern)
+		}
+
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("Invalid protection pattern %s: %s", pattern, err)
+		}
+	}
+
+	return &DeletionPolicy{NoPrune: !prune, Protect: protect, AllowDelete: allowDelete}, nil
+}
+
+// splitProtectPattern returns the entity type of a protection pattern, empty
+// when it matches every type, and the glob matching entity names
+func splitProtectPattern(pattern string) (string, string) {
+	parts := strings.SplitN(pattern, ":", 2)
+
+	if len(parts) == 1 {
+		return "", pattern
+	}
+
+	return strings.TrimSuffix(strings.ToLower(parts[0]), "s"), parts[1]
+}
+
+// protected tells whether an entity of kind named name matches a protection pattern
+func (p *DeletionPolicy) protected(kind, name string) bool {
+	for _, pattern := range p.Protect {
+		k, glob := splitProtectPattern(pattern)
+
+		if matched, _ := path.Match(glob, name); matched && (k == "" || k == kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keep returns the snapshot without the entities missing from the config the
+// policy keeps, so they are not deleted. Services still referenced by kept
+// routes are kept as well
+func (p *DeletionPolicy) Keep(config *Config, s *Snapshot) *Snapshot {
+	if p == nil || (!p.NoPrune && len(p.Protect) == 0) {
+		return s
+	}
+
+	live := newLiveIndex(s)
+	kept := func(kind, name string, desired map[string]bool) bool {
+		return !desired[name] && (p.NoPrune || p.protected(kind, name))
+	}
+
+	services := desiredNames(desiredServices(config))
+	routes := desiredNames(desiredRoutes(config))
+	consumers := desiredNames(desiredConsumers(config))
+	plugins := desiredNames(desiredPlugins(config))
+
+	k := s.subset()
+	referenced := make(map[string]bool)
+	keptConsumers := make(map[string]bool)
+
+	for _, e := range s.Routes {
+		if kept(KindRoute, live.routeNames[e.ID()], routes) {
+			referenced[foreignID(e, "service")] = true
+			continue
+		}
+
+		k.Routes = append(k.Routes, e)
+	}
+
+	for _, e := range s.Services {
+		name := stringField(e, "name")
+
+		if (services[name] || !referenced[e.ID()]) && !kept(KindService, name, services) {
+			k.Services = append(k.Services, e)
+		}
+	}
+
+	for _, e := range s.Consumers {
+		if kept(KindConsumer, stringField(e, "username"), consumers) {
+			keptConsumers[e.ID()] = true
+			continue
+		}
+
+		k.Consumers = append(k.Consumers, e)
+	}
+
+	for plugin, credentials := range s.Credentials {
+		for _, e := range credentials {
+			if !keptConsumers[foreignID(e, "consumer")] {
+				k.Credentials[plugin] = append(k.Credentials[plugin], e)
+			}
+		}
+	}
+
+	for _, e := range s.Plugins {
+		if !kept(KindPlugin, live.pluginScope(e), plugins) {
+			k.Plugins = append(k.Plugins, e)
+		}
+	}
+
+	return k
+}
+
+// Check fails when a plan deletes more entities than the policy allows
+func (p *DeletionPolicy) Check(plan *Plan) error {
+	removals := plan.Removals()
+
+	if p == nil || p.AllowDelete < 0 || len(removals) <= p.AllowDelete {
+		return nil
+	}
+
+	names := []string{}
+
+	for _, op := range removals {
+		names = append(names, op.Kind+" "+op.Name)
+	}
+
+	return fmt.Errorf("Applying the config would delete more entities missing from it than the %d allowed (%d): %s. Run with --allow-delete %d to delete them", p.AllowDelete, len(removals), strings.Join(names, ", "), len(removals))
+}
+
+// Removals returns the operations deleting entities the plan doesn't create again
+func (p *Plan) Removals() []Operation {
+	recreated := make(map[string]bool)
+
+	for _, op := range p.Operations {
+		if op.Action != ActionDelete {
+			recreated[op.Kind+"\x00"+op.Name] = true
+		}
+	}
+
+	removals := []Operation{}
+
+	for _, op := range p.Operations {
+		if op.Action == ActionDelete && !recreated[op.Kind+"\x00"+op.Name] {
+			removals = append(removals, op)
+		}
+	}
+
+	return removals
+}
+
+func desiredNames(entities []keyedEntity) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, e := range entities {
+		names[e.name] = true
+	}
+
+	return names
+}
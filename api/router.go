@@ -0,0 +1,381 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// Kong considers paths containing any other character to be regexes
+	plainPath = regexp.MustCompile(`^[a-zA-Z0-9.\-_~/%]*$`)
+	// Named capture groups as written in Kong's PCRE syntax
+	pcreNamedGroup = regexp.MustCompile(`\(\?<([a-zA-Z_][a-zA-Z0-9_]*)>`)
+)
+
+// Request represents an incoming request to match against the routes of a config
+type Request struct {
+	Protocol string              `json:"protocol"`
+	Method   string              `json:"method"`
+	Host     string              `json:"host"`
+	Path     string              `json:"path"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+}
+
+// Match represents the route a request is proxied through and the resulting upstream request
+type Match struct {
+	Route        string            `json:"route"`
+	Service      string            `json:"service"`
+	MatchedHost  string            `json:"matched_host,omitempty"`
+	MatchedPath  string            `json:"matched_path,omitempty"`
+	RegexPath    bool              `json:"regex_path,omitempty"`
+	Captures     map[string]string `json:"captures,omitempty"`
+	UpstreamURL  string            `json:"upstream_url"`
+	UpstreamHost string            `json:"upstream_host"`
+}
+
+// candidate is a route matching a request, with the details used to rank it
+type candidate struct {
+	index      int
+	route      Route
+	conditions int
+	plainHost  bool
+	host       string
+	path       string
+	regex      bool
+	prefix     string
+	captures   map[string]string
+}
+
+// MatchRoute finds the route Kong would proxy the request through, following
+// its router precedence rules:
+//
+//   - routes defining more matching attributes (hosts, headers, methods, paths) win
+//   - plain hosts win over wildcard hosts
+//   - regex paths are evaluated first by regex_priority, then prefix paths by length
+//   - remaining ties are broken by the order of the routes in the config
+//
+// It returns nil when no route matches
+func MatchRoute(config *Config, req Request) (*Match, error) {
+	if req.Protocol == "" {
+		req.Protocol = "http"
+	}
+
+	candidates := []candidate{}
+
+	for i, r := range config.Routes {
+		c, ok, err := matchRoute(r, req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			c.index = i
+			candidates = append(candidates, c)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].precedes(candidates[j])
+	})
+
+	best := candidates[0]
+
+	for _, s := range config.Services {
+		if s.Name == best.route.Service {
+			return upstream(best, s, req)
+		}
+	}
+
+	return nil, fmt.Errorf("Route %s applies to unknown service %s", best.route.Name, best.route.Service)
+}
+
+// precedes reports whether Kong evaluates candidate c before o
+func (c candidate) precedes(o candidate) bool {
+	if c.conditions != o.conditions {
+		return c.conditions > o.conditions
+	}
+
+	if c.plainHost != o.plainHost {
+		return c.plainHost
+	}
+
+	if c.regex != o.regex {
+		return c.regex
+	}
+
+	if c.regex && c.route.RegexPriority != o.route.RegexPriority {
+		return c.route.RegexPriority > o.route.RegexPriority
+	}
+
+	if !c.regex && len(c.path) != len(o.path) {
+		return len(c.path) > len(o.path)
+	}
+
+	return c.index < o.index
+}
+
+// matchRoute checks every matching attribute of a route against the request
+func matchRoute(r Route, req Request) (candidate, bool, error) {
+	c := candidate{route: r, plainHost: true}
+
+	protocols := r.Protocols
+
+	if len(protocols) == 0 {
+		protocols = []string{"http", "https"}
+	}
+
+	if !containsFold(protocols, req.Protocol) {
+		return c, false, nil
+	}
+
+	if len(r.Hosts) > 0 {
+		c.conditions++
+		matched := false
+
+		for _, h := range r.Hosts {
+			if matchHost(h, req.Host) {
+				matched = true
+				c.host = h
+				c.plainHost = !strings.Contains(h, "*")
+
+				if c.plainHost {
+					break
+				}
+			}
+		}
+
+		if !matched {
+			return c, false, nil
+		}
+	}
+
+	if len(r.Headers) > 0 {
+		c.conditions++
+
+		for name, values := range r.Headers {
+			if !containsAnyFold(values, headerValues(req.Headers, name)) {
+				return c, false, nil
+			}
+		}
+	}
+
+	if len(r.Methods) > 0 {
+		c.conditions++
+
+		if !containsFold(r.Methods, req.Method) {
+			return c, false, nil
+		}
+	}
+
+	if len(r.Paths) > 0 {
+		c.conditions++
+		matched := false
+
+		for _, p := range r.Paths {
+			prefix, captures, regex, err := matchPath(p, req.Path)
+
+			if err != nil {
+				return c, false, fmt.Errorf("Route %s has an invalid regex path %s: %s", r.Name, p, err)
+			}
+
+			if prefix == "" && captures == nil {
+				continue
+			}
+
+			// Keep the path evaluated first by Kong among the ones matching
+			if !matched || (regex && !c.regex) || (regex == c.regex && len(p) > len(c.path)) {
+				matched = true
+				c.path, c.prefix, c.captures, c.regex = p, prefix, captures, regex
+			}
+		}
+
+		if !matched {
+			return c, false, nil
+		}
+	}
+
+	return c, true, nil
+}
+
+// matchPath matches a route path against the request path. It returns the
+// matched prefix of the request path, and the named captures of regex paths
+func matchPath(path, requestPath string) (string, map[string]string, bool, error) {
+	if plainPath.MatchString(path) {
+		if strings.HasPrefix(requestPath, path) {
+			return path, nil, false, nil
+		}
+
+		return "", nil, false, nil
+	}
+
+	re, err := CompileRegexPath(path)
+
+	if err != nil {
+		return "", nil, true, err
+	}
+
+	m := re.FindStringSubmatch(requestPath)
+
+	if m == nil {
+		return "", nil, true, nil
+	}
+
+	captures := map[string]string{}
+
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			captures[name] = m[i]
+		}
+	}
+
+	return m[0], captures, true, nil
+}
+
+// CompileRegexPath compiles a regex route path the way Kong evaluates it,
+// anchored to the start of the request path
+func CompileRegexPath(path string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + pcreNamedGroup.ReplaceAllString(path, "(?P<$1>"))
+}
+
+// IsRegexPath reports whether Kong evaluates a route path as a regex
+func IsRegexPath(path string) bool {
+	return !plainPath.MatchString(path)
+}
+
+// matchHost matches a route host, possibly with a leading or trailing wildcard
+func matchHost(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+
+	// Hosts without a port match requests on any port
+	if !strings.Contains(pattern, ":") {
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	case strings.HasSuffix(pattern, ".*"):
+		return strings.HasPrefix(host, pattern[:len(pattern)-1])
+	default:
+		return pattern == host
+	}
+}
+
+// upstream builds the request Kong sends to the service of the matched route
+func upstream(c candidate, s Service, req Request) (*Match, error) {
+	target, err := serviceTarget(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestPath := req.Path
+
+	if c.route.StripPath && c.prefix != "" {
+		requestPath = strings.TrimPrefix(requestPath, c.prefix)
+	}
+
+	upstreamPath := joinURLPath(target.Path, requestPath)
+
+	m := &Match{
+		Route:        c.route.Name,
+		Service:      s.Name,
+		MatchedHost:  c.host,
+		MatchedPath:  c.path,
+		RegexPath:    c.regex,
+		Captures:     c.captures,
+		UpstreamURL:  fmt.Sprintf("%s://%s%s", target.Scheme, target.Host, upstreamPath),
+		UpstreamHost: target.Hostname(),
+	}
+
+	if c.route.PreserveHost {
+		m.UpstreamHost = req.Host
+	}
+
+	return m, nil
+}
+
+// serviceTarget returns the upstream URL of a service, from its url shorthand or attributes
+func serviceTarget(s Service) (*url.URL, error) {
+	if s.URL != "" {
+		return url.Parse(s.URL)
+	}
+
+	protocol, port := s.Protocol, s.Port
+
+	if protocol == "" {
+		protocol = "http"
+	}
+
+	host := s.Host
+
+	if port != 0 {
+		host = fmt.Sprintf("%s:%d", s.Host, port)
+	}
+
+	return &url.URL{Scheme: protocol, Host: host, Path: s.Path}, nil
+}
+
+// joinURLPath appends the request path to the path of the service the way Kong does
+func joinURLPath(base, path string) string {
+	switch {
+	case base == "" || base == "/":
+		if path == "" {
+			return "/"
+		}
+
+		if !strings.HasPrefix(path, "/") {
+			return "/" + path
+		}
+
+		return path
+	case path == "" || path == "/":
+		return base
+	case strings.HasSuffix(base, "/") && strings.HasPrefix(path, "/"):
+		return base + path[1:]
+	case !strings.HasSuffix(base, "/") && !strings.HasPrefix(path, "/"):
+		return base + "/" + path
+	default:
+		return base + path
+	}
+}
+
+func headerValues(headers map[string][]string, name string) []string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAnyFold(list, values []string) bool {
+	for _, v := range values {
+		if containsFold(list, v) {
+			return true
+		}
+	}
+
+	return false
+}
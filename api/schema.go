@@ -2,28 +2,40 @@ package api
 
 // Config models the top-level structure of the config YAML file
 type Config struct {
-	Host        string       `yaml:"host"`
-	HTTPS       bool         `yaml:"https"`
-	Version     string       `yaml:"version"`
-	Services    []Service    `yaml:"services"`
-	Routes      []Route      `yaml:"routes"`
-	Plugins     []Plugin     `yaml:"plugins"`
-	Consumers   []Consumer   `yaml:"consumers,omitempty"`
-	Credentials []Credential `yaml:"credentials,omitempty"`
+	Host    string `yaml:"host"`
+	HTTPS   bool   `yaml:"https"`
+	Version string `yaml:"version"`
+	// Workspace of Kong Enterprise the entities belong to, the default workspace when empty
+	Workspace string `yaml:"workspace,omitempty"`
+	// Headers are sent with every Admin API request, eg: Kong-Admin-Token
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Clusters are the Kong instances the config is applied to, instead of Host
+	Clusters []Cluster `yaml:"clusters,omitempty"`
+	Services []Service `yaml:"services"`
+	Routes   []Route   `yaml:"routes"`
+	Plugins  []Plugin  `yaml:"plugins"`
+	// PluginTemplates are reusable plugin configs, resolved when the config is parsed
+	PluginTemplates []PluginTemplate `yaml:"plugin_templates,omitempty"`
+	Consumers       []Consumer       `yaml:"consumers,omitempty"`
+	Credentials     []Credential     `yaml:"credentials,omitempty"`
 }
 
 // Route represents a route for a microservice
 type Route struct {
-	Name          string   `yaml:"name,omitempty" json:"-"`
-	ID            string   `yaml:"id,omitempty" json:"id,omitempty"`
-	Service       string   `yaml:"apply_to,omitempty" json:"service,omitempty"`
-	Hosts         []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
-	Paths         []string `yaml:"paths,omitempty" json:"paths,omitempty"`
-	Methods       []string `yaml:"methods,omitempty" json:"methods,omitempty"`
-	StripPath     bool     `yaml:"strip_path,omitempty" json:"strip_path"`
-	Protocols     []string `yaml:"protocols,omitempty" json:"protocols,omitempty"`
-	RegexPriority int      `yaml:"regex_priority,omitempty" json:"regex_priority,omitempty"`
-	PreserveHost  bool     `yaml:"preserve_host,omitempty" json:"preserve_host"`
+	Name          string              `yaml:"name,omitempty" json:"name,omitempty"`
+	ID            string              `yaml:"id,omitempty" json:"id,omitempty"`
+	Service       string              `yaml:"apply_to,omitempty" json:"service,omitempty"`
+	Hosts         []string            `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	Paths         []string            `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Methods       []string            `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Headers       map[string][]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	StripPath     bool                `yaml:"strip_path,omitempty" json:"strip_path"`
+	Protocols     []string            `yaml:"protocols,omitempty" json:"protocols,omitempty"`
+	RegexPriority int                 `yaml:"regex_priority,omitempty" json:"regex_priority,omitempty"`
+	PreserveHost  bool                `yaml:"preserve_host,omitempty" json:"preserve_host"`
+	Tags          []string            `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// Plugins applied to the route, moved to the plugins of the config when parsed
+	Plugins []Plugin `yaml:"plugins,omitempty" json:"-"`
 }
 
 // Service represents the upstream microservice
@@ -38,6 +50,12 @@ type Service struct {
 	ReadTimeout    int    `yaml:"read_timeout,omitempty" json:"read_timeout,omitempty"`
 	Retries        int    `yaml:"retries,omitempty" json:"retries,omitempty"`
 	Protocol       string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	// Enabled is only sent when set, as older versions of Kong can't disable services
+	Enabled *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Tags    []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// Routes and plugins of the service, moved to the routes and plugins of the config when parsed
+	Routes  []Route  `yaml:"routes,omitempty" json:"-"`
+	Plugins []Plugin `yaml:"plugins,omitempty" json:"-"`
 }
 
 // Services represents the response body returned from GET /services, a Kong API endpoint
@@ -56,8 +74,9 @@ type Routes struct {
 
 // Consumer represents the user credential for authentication to Kong
 type Consumer struct {
-	Username string `json:"username" yaml:"username"`
-	CustomID string `json:"custom_id,omitempty" yaml:"custom_id"`
+	Username string   `json:"username" yaml:"username"`
+	CustomID string   `json:"custom_id,omitempty" yaml:"custom_id,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
 }
 
 // Consumers represents the response body returned from GET /consumers, a Kong API endpoint
@@ -68,15 +87,14 @@ type Consumers struct {
 
 // Credential represents user
 type Credential struct {
-	Name   string           	  `yaml:"name" json:"-"`
-	Target string           	  `yaml:"target" json:"-"`
-	ID string `json:"id"`
-	Key string `json:"key"`
-	Secret string `json:"secret"`
+	Name   string                 `yaml:"name" json:"-"`
+	Target string                 `yaml:"target" json:"-"`
+	ID     string                 `yaml:"id,omitempty" json:"id"`
+	Key    string                 `yaml:"key,omitempty" json:"key"`
+	Secret string                 `yaml:"secret,omitempty" json:"secret"`
 	Config map[string]interface{} `yaml:"config,omitempty"`
 }
 
-
 // Plugins represents the response body of GET /plugins endpoint of Kong Admin API
 type Plugins struct {
 	Next string   `yaml:"next,omitempty" json:"next,omitempty"`
@@ -87,11 +105,13 @@ type Plugins struct {
 type Plugin struct {
 	ID       string                 `yaml:"id,omitempty" json:"id,omitempty"`
 	Name     string                 `yaml:"name,omitempty" json:"name,omitempty"`
-	Enabled  bool                   `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Enabled  *bool                  `yaml:"enabled,omitempty" json:"enabled,omitempty"`
 	Services []string               `yaml:"services,omitempty" json:"-"`
 	Routes   []string               `yaml:"routes,omitempty" json:"-"`
 	Target   string                 `yaml:"target,omitempty" json:"-"`
+	Extends  string                 `yaml:"extends,omitempty" json:"-"`
 	Config   map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+	Tags     []string               `yaml:"tags,omitempty" json:"tags,omitempty"`
 }
 
 type HeaderList struct {
@@ -0,0 +1,29 @@
+// Code generated by gen_schemas.go; DO NOT EDIT.
+
+package api
+
+// Schemas of core plugins and credentials, keyed by Kong version
+var bundled = map[string]bundledSchemas{
+	"1.1": {
+		"credential/acls":              "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"group\":{\"type\":\"string\",\"required\":true}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"credential/basic-auth":        "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"username\":{\"type\":\"string\",\"required\":true,\"unique\":true}},{\"password\":{\"type\":\"string\",\"required\":true}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"credential/hmac-auth":         "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"username\":{\"type\":\"string\",\"required\":true,\"unique\":true}},{\"secret\":{\"type\":\"string\",\"auto\":true}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"credential/jwt":               "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"key\":{\"type\":\"string\",\"unique\":true}},{\"secret\":{\"type\":\"string\",\"auto\":true}},{\"rsa_public_key\":{\"type\":\"string\"}},{\"algorithm\":{\"type\":\"string\",\"default\":\"HS256\",\"one_of\":[\"HS256\",\"HS384\",\"HS512\",\"RS256\",\"RS512\",\"ES256\"]}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"credential/key-auth":          "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"key\":{\"type\":\"string\",\"required\":false,\"unique\":true,\"auto\":true}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"credential/oauth2":            "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"required\":true,\"on_delete\":\"cascade\"}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"client_id\":{\"type\":\"string\",\"required\":false,\"unique\":true,\"auto\":true}},{\"client_secret\":{\"type\":\"string\",\"required\":false,\"auto\":true}},{\"redirect_uris\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"required\":false}},{\"tags\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"}}}]}",
+		"plugin/acl":                   "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"whitelist\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"blacklist\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"hide_groups_header\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/basic-auth":            "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"anonymous\":{\"type\":\"string\",\"uuid\":true,\"legacy\":true}},{\"hide_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/correlation-id":        "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"header_name\":{\"type\":\"string\",\"default\":\"Kong-Request-ID\"}},{\"generator\":{\"type\":\"string\",\"default\":\"uuid#counter\",\"one_of\":[\"uuid\",\"uuid#counter\",\"tracker\"]}},{\"echo_downstream\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/cors":                  "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"origins\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"exposed_headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"methods\":{\"type\":\"array\",\"elements\":{\"type\":\"string\",\"one_of\":[\"GET\",\"HEAD\",\"PUT\",\"PATCH\",\"POST\",\"DELETE\",\"OPTIONS\",\"TRACE\",\"CONNECT\"]},\"default\":[\"GET\",\"HEAD\",\"PUT\",\"PATCH\",\"POST\"]}},{\"max_age\":{\"type\":\"number\"}},{\"credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"preflight_continue\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/file-log":              "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"path\":{\"type\":\"string\",\"required\":true,\"match\":\"^[^*&%%\\\\`]+$\"}},{\"reopen\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/hmac-auth":             "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"hide_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"clock_skew\":{\"type\":\"number\",\"default\":300,\"gt\":0}},{\"anonymous\":{\"type\":\"string\",\"uuid\":true,\"legacy\":true}},{\"validate_request_body\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"enforce_headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"algorithms\":{\"type\":\"array\",\"elements\":{\"type\":\"string\",\"one_of\":[\"hmac-sha1\",\"hmac-sha256\",\"hmac-sha384\",\"hmac-sha512\"]},\"default\":[\"hmac-sha1\",\"hmac-sha256\",\"hmac-sha384\",\"hmac-sha512\"]}}],\"required\":true}}]}",
+		"plugin/http-log":              "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"http_endpoint\":{\"type\":\"string\",\"required\":true}},{\"method\":{\"type\":\"string\",\"default\":\"POST\",\"one_of\":[\"POST\",\"PUT\",\"PATCH\"]}},{\"content_type\":{\"type\":\"string\",\"default\":\"application/json\",\"one_of\":[\"application/json\"]}},{\"timeout\":{\"type\":\"number\",\"default\":10000}},{\"keepalive\":{\"type\":\"number\",\"default\":60000}},{\"retry_count\":{\"type\":\"integer\",\"default\":10}},{\"queue_size\":{\"type\":\"integer\",\"default\":1}},{\"flush_timeout\":{\"type\":\"number\",\"default\":2}}],\"required\":true}}]}",
+		"plugin/ip-restriction":        "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"whitelist\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"blacklist\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}}],\"required\":true}}]}",
+		"plugin/jwt":                   "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"uri_param_names\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"},\"default\":[\"jwt\"]}},{\"cookie_names\":{\"type\":\"set\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"key_claim_name\":{\"type\":\"string\",\"default\":\"iss\"}},{\"secret_is_base64\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"claims_to_verify\":{\"type\":\"set\",\"elements\":{\"type\":\"string\",\"one_of\":[\"exp\",\"nbf\"]}}},{\"anonymous\":{\"type\":\"string\",\"uuid\":true,\"legacy\":true}},{\"run_on_preflight\":{\"type\":\"boolean\",\"default\":true,\"required\":true}},{\"maximum_expiration\":{\"type\":\"number\",\"default\":0,\"between\":[0,31536000]}}],\"required\":true}}]}",
+		"plugin/key-auth":              "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"key_names\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[\"apikey\"],\"required\":true}},{\"hide_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"anonymous\":{\"type\":\"string\",\"uuid\":true,\"legacy\":true}},{\"key_in_body\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"run_on_preflight\":{\"type\":\"boolean\",\"default\":true,\"required\":true}}],\"required\":true}}]}",
+		"plugin/oauth2":                "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"scopes\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"}}},{\"mandatory_scope\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"provision_key\":{\"type\":\"string\",\"unique\":true,\"auto\":true,\"required\":true}},{\"token_expiration\":{\"type\":\"number\",\"default\":7200,\"required\":true}},{\"enable_authorization_code\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"enable_implicit_grant\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"enable_client_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"enable_password_grant\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"hide_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"accept_http_if_already_terminated\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"anonymous\":{\"type\":\"string\",\"uuid\":true,\"legacy\":true}},{\"global_credentials\":{\"type\":\"boolean\",\"default\":false,\"required\":true}},{\"auth_header_name\":{\"type\":\"string\",\"default\":\"authorization\"}},{\"refresh_token_ttl\":{\"type\":\"number\",\"default\":1209600,\"required\":true,\"between\":[0,100000000]}}],\"required\":true}}]}",
+		"plugin/rate-limiting":         "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"second\":{\"type\":\"number\",\"gt\":0}},{\"minute\":{\"type\":\"number\",\"gt\":0}},{\"hour\":{\"type\":\"number\",\"gt\":0}},{\"day\":{\"type\":\"number\",\"gt\":0}},{\"month\":{\"type\":\"number\",\"gt\":0}},{\"year\":{\"type\":\"number\",\"gt\":0}},{\"limit_by\":{\"type\":\"string\",\"default\":\"consumer\",\"one_of\":[\"consumer\",\"credential\",\"ip\"]}},{\"policy\":{\"type\":\"string\",\"default\":\"cluster\",\"len_min\":0,\"one_of\":[\"local\",\"cluster\",\"redis\"]}},{\"fault_tolerant\":{\"type\":\"boolean\",\"default\":true,\"required\":true}},{\"redis_host\":{\"type\":\"string\"}},{\"redis_port\":{\"type\":\"integer\",\"default\":6379}},{\"redis_password\":{\"type\":\"string\",\"len_min\":0}},{\"redis_timeout\":{\"type\":\"number\",\"default\":2000}},{\"redis_database\":{\"type\":\"integer\",\"default\":0}},{\"hide_client_headers\":{\"type\":\"boolean\",\"default\":false,\"required\":true}}],\"required\":true}}]}",
+		"plugin/request-size-limiting": "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"allowed_payload_size\":{\"type\":\"integer\",\"default\":128}}],\"required\":true}}]}",
+		"plugin/request-transformer":   "{\"fields\":[{\"id\":{\"type\":\"string\",\"uuid\":true,\"auto\":true}},{\"name\":{\"type\":\"string\",\"required\":true}},{\"created_at\":{\"type\":\"integer\",\"timestamp\":true,\"auto\":true}},{\"route\":{\"type\":\"foreign\",\"reference\":\"routes\",\"default\":null}},{\"service\":{\"type\":\"foreign\",\"reference\":\"services\",\"default\":null}},{\"consumer\":{\"type\":\"foreign\",\"reference\":\"consumers\",\"default\":null}},{\"run_on\":{\"type\":\"string\",\"default\":\"first\",\"one_of\":[\"first\",\"second\",\"all\"],\"required\":true}},{\"protocols\":{\"type\":\"set\",\"default\":[\"http\",\"https\"],\"required\":true,\"elements\":{\"type\":\"string\",\"one_of\":[\"http\",\"https\",\"tcp\",\"tls\"]}}},{\"enabled\":{\"type\":\"boolean\",\"default\":true}},{\"config\":{\"type\":\"record\",\"fields\":[{\"http_method\":{\"type\":\"string\",\"match\":\"^%u+$\"}},{\"remove\":{\"type\":\"record\",\"fields\":[{\"body\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"querystring\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}}],\"required\":true}},{\"rename\":{\"type\":\"record\",\"fields\":[{\"body\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"querystring\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}}],\"required\":true}},{\"replace\":{\"type\":\"record\",\"fields\":[{\"body\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"querystring\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"uri\":{\"type\":\"string\"}}],\"required\":true}},{\"add\":{\"type\":\"record\",\"fields\":[{\"body\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"querystring\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}}],\"required\":true}},{\"append\":{\"type\":\"record\",\"fields\":[{\"body\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"headers\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}},{\"querystring\":{\"type\":\"array\",\"elements\":{\"type\":\"string\"},\"default\":[]}}],\"required\":true}}],\"required\":true}}]}",
+	},
+}
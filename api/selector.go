@@ -0,0 +1,250 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selector restricts an apply or a diff to some of the entities of the config
+// and of Kong, the others being neither compared, changed nor deleted.
+// Entities Kong deletes along with selected ones are selected as well: the
+// routes of services, the plugins of routes and services and the credentials
+// of consumers, as kongfig recreates them
+type Selector struct {
+	// Kinds of entities selected, eg: service or plugin, every kind when empty
+	Kinds []string
+	// Tags every selected entity has
+	Tags []string
+	// Services selected along with their routes and plugins
+	Services []string
+}
+
+// NewSelector returns a selector of entities of the given kinds, accepting
+// plural names as well, eg: services,plugins
+func NewSelector(kinds, tags, services []string) (*Selector, error) {
+	s := &Selector{Tags: tags, Services: services}
+
+	for _, kind := range kinds {
+		k := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(kind)), "s")
+
+		if _, ok := kindOrder[k]; !ok {
+			return nil, fmt.Errorf("Unknown entity type %s, expected one of: services, routes, plugins, consumers, credentials", kind)
+		}
+
+		s.Kinds = append(s.Kinds, k)
+	}
+
+	return s, nil
+}
+
+// Empty tells whether the selector selects everything
+func (s *Selector) Empty() bool {
+	return s == nil || (len(s.Kinds) == 0 && len(s.Tags) == 0 && len(s.Services) == 0)
+}
+
+// matches tells whether an entity of kind, applying to service and having tags is selected by itself
+func (s *Selector) matches(kind, service string, tags []string) bool {
+	if len(s.Kinds) > 0 && !contains(s.Kinds, kind) {
+		return false
+	}
+
+	if len(s.Services) > 0 && !contains(s.Services, service) {
+		return false
+	}
+
+	for _, tag := range s.Tags {
+		if !contains(tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Select returns the selected entities of a config and of a snapshot of Kong
+func (s *Selector) Select(config *Config, snapshot *Snapshot) (*Config, *Snapshot) {
+	if s.Empty() {
+		return config, snapshot
+	}
+
+	live := newLiveIndex(snapshot)
+	routeServices := make(map[string]string)
+
+	for _, r := range config.Routes {
+		routeServices[routeName(r.Name, r.Service, r.Paths)] = r.Service
+	}
+
+	for _, e := range snapshot.Routes {
+		routeServices[live.routeNames[e.ID()]] = live.serviceNames[foreignID(e, "service")]
+	}
+
+	services := make(map[string]bool)
+
+	for _, svc := range config.Services {
+		services[svc.Name] = services[svc.Name] || s.matches(KindService, svc.Name, svc.Tags)
+	}
+
+	for _, e := range snapshot.Services {
+		name := stringField(e, "name")
+		services[name] = services[name] || s.matches(KindService, name, stringList(e["tags"]))
+	}
+
+	routes := make(map[string]bool)
+	routeSelected := func(name string, tags []string) bool {
+		service := routeServices[name]
+		return services[service] || s.matches(KindRoute, service, tags)
+	}
+
+	for _, r := range config.Routes {
+		name := routeName(r.Name, r.Service, r.Paths)
+		routes[name] = routes[name] || routeSelected(name, r.Tags)
+	}
+
+	for _, e := range snapshot.Routes {
+		name := live.routeNames[e.ID()]
+		routes[name] = routes[name] || routeSelected(name, stringList(e["tags"]))
+	}
+
+	consumers := make(map[string]bool)
+
+	for _, c := range config.Consumers {
+		consumers[c.Username] = consumers[c.Username] || s.matches(KindConsumer, "", c.Tags)
+	}
+
+	for _, e := range snapshot.Consumers {
+		name := stringField(e, "username")
+		consumers[name] = consumers[name] || s.matches(KindConsumer, "", stringList(e["tags"]))
+	}
+
+	// Consumers are recreated with all their credentials
+	for _, c := range config.Credentials {
+		consumers[c.Target] = consumers[c.Target] || s.matches(KindCredential, "", stringList(c.Config["tags"]))
+	}
+
+	for _, credentials := range snapshot.Credentials {
+		for _, e := range credentials {
+			name := live.consumerNames[foreignID(e, "consumer")]
+			consumers[name] = consumers[name] || s.matches(KindCredential, "", stringList(e["tags"]))
+		}
+	}
+
+	pluginSelected := func(service, route string, tags []string) bool {
+		if route != "" {
+			return routes[route] || s.matches(KindPlugin, routeServices[route], tags)
+		}
+
+		return services[service] || s.matches(KindPlugin, service, tags)
+	}
+
+	c := *config
+	c.Services, c.Routes, c.Plugins, c.Consumers, c.Credentials = nil, nil, nil, nil, nil
+
+	for _, svc := range config.Services {
+		if services[svc.Name] {
+			c.Services = append(c.Services, svc)
+		}
+	}
+
+	for _, r := range config.Routes {
+		if routes[routeName(r.Name, r.Service, r.Paths)] {
+			c.Routes = append(c.Routes, r)
+		}
+	}
+
+	for _, p := range config.Plugins {
+		if p.Target == "global" {
+			if pluginSelected("", "", p.Tags) {
+				c.Plugins = append(c.Plugins, p)
+			}
+
+			continue
+		}
+
+		// Plugins applying to several services or routes only keep the selected ones
+		selected := p
+		selected.Services, selected.Routes = nil, nil
+
+		for _, service := range p.Services {
+			if pluginSelected(service, "", p.Tags) {
+				selected.Services = append(selected.Services, service)
+			}
+		}
+
+		for _, route := range p.Routes {
+			if pluginSelected("", route, p.Tags) {
+				selected.Routes = append(selected.Routes, route)
+			}
+		}
+
+		if len(selected.Services) > 0 || len(selected.Routes) > 0 {
+			c.Plugins = append(c.Plugins, selected)
+		}
+	}
+
+	for _, consumer := range config.Consumers {
+		if consumers[consumer.Username] {
+			c.Consumers = append(c.Consumers, consumer)
+		}
+	}
+
+	for _, cred := range config.Credentials {
+		if consumers[cred.Target] {
+			c.Credentials = append(c.Credentials, cred)
+		}
+	}
+
+	snap := snapshot.subset()
+
+	for _, e := range snapshot.Services {
+		if services[stringField(e, "name")] {
+			snap.Services = append(snap.Services, e)
+		}
+	}
+
+	for _, e := range snapshot.Routes {
+		if routes[live.routeNames[e.ID()]] {
+			snap.Routes = append(snap.Routes, e)
+		}
+	}
+
+	for _, e := range snapshot.Plugins {
+		// Plugins applied to consumers go along with their consumer
+		if consumer := foreignID(e, "consumer"); consumer != "" {
+			if consumers[live.consumerNames[consumer]] || s.matches(KindPlugin, "", stringList(e["tags"])) {
+				snap.Plugins = append(snap.Plugins, e)
+			}
+
+			continue
+		}
+
+		if pluginSelected(live.serviceNames[foreignID(e, "service")], live.routeNames[foreignID(e, "route")], stringList(e["tags"])) {
+			snap.Plugins = append(snap.Plugins, e)
+		}
+	}
+
+	for _, e := range snapshot.Consumers {
+		if consumers[stringField(e, "username")] {
+			snap.Consumers = append(snap.Consumers, e)
+		}
+	}
+
+	for plugin, credentials := range snapshot.Credentials {
+		for _, e := range credentials {
+			if consumers[live.consumerNames[foreignID(e, "consumer")]] {
+				snap.Credentials[plugin] = append(snap.Credentials[plugin], e)
+			}
+		}
+	}
+
+	return &c, snap
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+var (
+	errNotFound = errors.New("[HTTP 404] Not found")
+
+	// Maps credential plugin names, as used in the config file, to the
+	// top-level Admin API collection listing all credentials of that type
+	credentialCollections = map[string]string{
+		"acls":       "acls",
+		"basic-auth": "basic-auths",
+		"hmac-auth":  "hmac-auths",
+		"jwt":        "jwts",
+		"key-auth":   "key-auths",
+		"oauth2":     "oauth2",
+	}
+)
+
+// Entity represents a raw Kong entity exactly as returned by the Admin API
+type Entity map[string]interface{}
+
+// ID returns the primary key of the entity
+func (e Entity) ID() string {
+	id, _ := e["id"].(string)
+
+	return id
+}
+
+// Snapshot represents the full state of a Kong instance at a point in time
+type Snapshot struct {
+	Host        string              `json:"host"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Services    []Entity            `json:"services"`
+	Routes      []Entity            `json:"routes"`
+	Plugins     []Entity            `json:"plugins"`
+	Consumers   []Entity            `json:"consumers"`
+	Credentials map[string][]Entity `json:"credentials"`
+
+	// source is the snapshot a selection was made from, resolving the foreign keys of its entities
+	source *Snapshot
+}
+
+// subset returns an empty snapshot of the same Kong, resolving the foreign
+// keys of the entities added to it with the entities of s
+func (s *Snapshot) subset() *Snapshot {
+	sub := *s
+	sub.source = s
+
+	if s.source != nil {
+		sub.source = s.source
+	}
+
+	sub.Services, sub.Routes, sub.Plugins, sub.Consumers = []Entity{}, []Entity{}, []Entity{}, []Entity{}
+	sub.Credentials = make(map[string][]Entity)
+
+	return &sub
+}
+
+// page represents a single page of any paginated Kong collection
+type page struct {
+	Next string   `json:"next,omitempty"`
+	Data []Entity `json:"data,omitempty"`
+}
+
+// getAll fetches every page of a Kong collection, eg: /services
+func (c *Client) getAll(path string) ([]Entity, error) {
+	entities := []Entity{}
+	url := c.BaseURL + path
+
+	for url != "" {
+		c.Logger.Debugf("Fetching %s", url)
+
+		p := page{}
+		res, err := c.httpRequest(http.MethodGet, url, nil, &p)
+
+		if err != nil {
+			return entities, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			return entities, errNotFound
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return entities, fmt.Errorf("[HTTP %d] Error fetching %s. Bad response from the API", res.StatusCode, path)
+		}
+
+		entities = append(entities, p.Data...)
+		url = c.nextURL(p.Next)
+	}
+
+	return entities, nil
+}
+
+// nextURL resolves the "next" attribute of a paginated response, which Kong
+// returns either as an absolute URL or as a path relative to the Admin API,
+// starting with the workspace in Kong Enterprise
+func (c *Client) nextURL(next string) string {
+	if next == "" || strings.HasPrefix(next, "http") {
+		return next
+	}
+
+	if ws := workspacePath(c.workspace()); ws != "" && strings.HasPrefix(next, ws+"/") {
+		return c.rootURL() + next
+	}
+
+	return c.BaseURL + next
+}
+
+// TakeSnapshot fetches services, routes, plugins, consumers and credentials
+// from Kong, keeping every attribute including the entity IDs
+func (c *Client) TakeSnapshot() (*Snapshot, error) {
+	var err error
+
+	s := &Snapshot{
+		Host:        c.BaseURL,
+		CreatedAt:   time.Now().UTC(),
+		Credentials: make(map[string][]Entity),
+	}
+
+	if s.Services, err = c.getAll("/services"); err != nil {
+		if err == errNotFound && c.workspace() != "" {
+			return nil, fmt.Errorf("Workspace %s doesn't exist in Kong at %s", c.workspace(), c.rootURL())
+		}
+
+		return nil, err
+	}
+
+	if s.Routes, err = c.getAll("/routes"); err != nil {
+		return nil, err
+	}
+
+	if s.Plugins, err = c.getAll("/plugins"); err != nil {
+		return nil, err
+	}
+
+	if s.Consumers, err = c.getAll("/consumers"); err != nil {
+		return nil, err
+	}
+
+	s.Consumers = withoutLock(s.Consumers)
+
+	// Credentials are fetched in a stable order, so runs make the same requests
+	for _, name := range sortedCredentialTypes() {
+		credentials, err := c.getAll("/" + credentialCollections[name])
+
+		// Credential endpoints only exist when the plugin is installed
+		if err == errNotFound {
+			c.Logger.Debugf("Plugin %s is not installed, skipping its credentials", name)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(credentials) > 0 {
+			s.Credentials[name] = credentials
+		}
+	}
+
+	return s, nil
+}
+
+// Save writes the snapshot to a timestamped file inside dir and returns its path
+func (s *Snapshot) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("kongfig-snapshot-%s.json", s.CreatedAt.Format(snapshotTimeFormat)))
+
+	return path, ioutil.WriteFile(path, data, 0600)
+}
+
+// LoadSnapshot reads a snapshot previously written by Save
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Error parsing snapshot %s: %s", path, err)
+	}
+
+	return s, nil
+}
+
+// Restore brings Kong back to exactly the state recorded in the snapshot.
+// Everything currently configured is deleted, then every entity is recreated
+// with a PUT on its original ID
+func (c *Client) Restore(s *Snapshot) error {
+	return c.track(KindSnapshot, s.CreatedAt.Format(time.RFC3339), ActionRestore, func() (int, error) {
+		return 0, c.restore(s)
+	})
+}
+
+func (c *Client) restore(s *Snapshot) error {
+	current, err := c.TakeSnapshot()
+
+	if err != nil {
+		return err
+	}
+
+	// Deleting consumers deletes credentials as well
+	for _, collection := range []struct {
+		kind     string
+		path     string
+		entities []Entity
+	}{
+		{KindPlugin, "/plugins", current.Plugins},
+		{KindConsumer, "/consumers", current.Consumers},
+		{KindRoute, "/routes", current.Routes},
+		{KindService, "/services", current.Services},
+	} {
+		for _, e := range collection.entities {
+			if err := c.deleteEntity(collection.kind, collection.path, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, collection := range []struct {
+		kind     string
+		path     string
+		entities []Entity
+	}{
+		{KindService, "/services", s.Services},
+		{KindRoute, "/routes", s.Routes},
+		{KindConsumer, "/consumers", s.Consumers},
+	} {
+		for _, e := range collection.entities {
+			if err := c.putEntity(collection.kind, collection.path, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(s.Credentials) {
+		for _, e := range s.Credentials[name] {
+			path := fmt.Sprintf("/consumers/%s/%s", foreignID(e, "consumer"), name)
+
+			if err := c.putEntity(KindCredential, path, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, e := range s.Plugins {
+		if err := c.putEntity(KindPlugin, "/plugins", e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// displayName returns the most meaningful identifier of an entity for reporting
+func (e Entity) displayName() string {
+	for _, attribute := range []string{"name", "username"} {
+		if name := stringField(e, attribute); name != "" {
+			return name
+		}
+	}
+
+	return e.ID()
+}
+
+// putEntity creates or replaces an entity of the collection at path using its original ID
+func (c *Client) putEntity(kind, path string, e Entity) error {
+	return c.track(kind, e.displayName(), ActionRestore, func() (int, error) {
+		url := fmt.Sprintf("%s%s/%s", c.BaseURL, path, e.ID())
+
+		payload, err := json.Marshal(e)
+
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := c.httpRequest(http.MethodPut, url, payload, nil)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error restoring %s/%s. Bad response from the API", res.StatusCode, path, e.ID())
+		}
+
+		return res.StatusCode, nil
+	})
+}
+
+// deleteEntity deletes an entity of the collection at path based on its ID
+func (c *Client) deleteEntity(kind, path string, e Entity) error {
+	return c.track(kind, e.displayName(), ActionDelete, func() (int, error) {
+		url := fmt.Sprintf("%s%s/%s", c.BaseURL, path, e.ID())
+
+		res, err := c.httpRequest(http.MethodDelete, url, nil, nil)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error deleting %s/%s. Bad response from the API", res.StatusCode, path, e.ID())
+		}
+
+		return res.StatusCode, nil
+	})
+}
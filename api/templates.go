@@ -0,0 +1,156 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PluginTemplate is a reusable plugin config, extended by plugins and other
+// templates through their extends attribute
+type PluginTemplate struct {
+	Name    string                 `yaml:"name"`
+	Plugin  string                 `yaml:"plugin,omitempty"`
+	Extends string                 `yaml:"extends,omitempty"`
+	Enabled *bool                  `yaml:"enabled,omitempty"`
+	Config  map[string]interface{} `yaml:"config,omitempty"`
+}
+
+// resolveTemplates replaces the plugins extending a template with the result
+// of deep merging their attributes over the ones of the template
+func resolveTemplates(config *Config) error {
+	templates := make(map[string]PluginTemplate)
+
+	for _, t := range config.PluginTemplates {
+		if _, ok := templates[t.Name]; ok {
+			return fmt.Errorf("Plugin template %s is defined more than once", t.Name)
+		}
+
+		templates[t.Name] = t
+	}
+
+	for i, p := range config.Plugins {
+		if p.Extends == "" {
+			continue
+		}
+
+		t, err := resolveTemplate(templates, p.Extends, nil)
+
+		if err != nil {
+			return fmt.Errorf("Plugin %s: %s", pluginLabel(p), err)
+		}
+
+		if p.Name == "" {
+			p.Name = t.Plugin
+		}
+
+		if t.Plugin != "" && p.Name != t.Plugin {
+			return fmt.Errorf("Plugin %s extends template %s of plugin %s", p.Name, p.Extends, t.Plugin)
+		}
+
+		if p.Enabled == nil {
+			p.Enabled = t.Enabled
+		}
+
+		p.Config = mergeConfig(t.Config, p.Config)
+		config.Plugins[i] = p
+	}
+
+	return nil
+}
+
+// resolveTemplate returns a template merged over the templates it extends
+func resolveTemplate(templates map[string]PluginTemplate, name string, chain []string) (PluginTemplate, error) {
+	for _, seen := range chain {
+		if seen == name {
+			return PluginTemplate{}, fmt.Errorf("plugin templates extend each other: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+
+	t, ok := templates[name]
+
+	if !ok {
+		return PluginTemplate{}, fmt.Errorf("extends unknown plugin template %s", name)
+	}
+
+	if t.Extends == "" {
+		return t, nil
+	}
+
+	parent, err := resolveTemplate(templates, t.Extends, append(chain, name))
+
+	if err != nil {
+		return PluginTemplate{}, err
+	}
+
+	if t.Plugin == "" {
+		t.Plugin = parent.Plugin
+	}
+
+	if parent.Plugin != "" && t.Plugin != parent.Plugin {
+		return PluginTemplate{}, fmt.Errorf("plugin template %s of plugin %s extends template %s of plugin %s", t.Name, t.Plugin, parent.Name, parent.Plugin)
+	}
+
+	if t.Enabled == nil {
+		t.Enabled = parent.Enabled
+	}
+
+	t.Config = mergeConfig(parent.Config, t.Config)
+
+	return t, nil
+}
+
+// mergeConfig deep merges override over base. Objects are merged, other
+// values replace the ones of base, and null removes them
+func mergeConfig(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+
+	for k, v := range base {
+		merged[k] = copyValue(v)
+	}
+
+	for k, v := range override {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+
+		baseMap, baseIsMap := merged[k].(map[string]interface{})
+		overrideMap, overrideIsMap := v.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeConfig(baseMap, overrideMap)
+		} else {
+			merged[k] = copyValue(v)
+		}
+	}
+
+	return merged
+}
+
+// copyValue deep copies a decoded YAML value, so plugins extending the same
+// template don't share objects or lists
+func copyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return mergeConfig(value, nil)
+	case []interface{}:
+		list := make([]interface{}, len(value))
+
+		for i, item := range value {
+			list[i] = copyValue(item)
+		}
+
+		return list
+	}
+
+	return v
+}
+
+// pluginLabel names a plugin in errors, before templates provide its name
+func pluginLabel(p Plugin) string {
+	if p.Name != "" {
+		return p.Name
+	}
+
+	return "extending " + p.Extends
+}
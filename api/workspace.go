@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// workspace returns the Kong Enterprise workspace the client reaches, empty
+// when the config doesn't set one and Kong uses its default workspace
+func (c *Client) workspace() string {
+	if c.config == nil {
+		return ""
+	}
+
+	return c.config.Workspace
+}
+
+// workspacePath returns the prefix of the Admin API paths of a workspace, eg: /team-a
+func workspacePath(workspace string) string {
+	if workspace == "" {
+		return ""
+	}
+
+	return "/" + url.PathEscape(workspace)
+}
+
+// rootURL returns the URL of the Admin API outside of the workspace, for the
+// endpoints Kong doesn't scope to workspaces, eg: / or /workspaces
+func (c *Client) rootURL() string {
+	return strings.TrimSuffix(c.BaseURL, workspacePath(c.workspace()))
+}
+
+// WorkspaceExists tells whether the workspace of the client exists in Kong,
+// the default workspace always does
+func (c *Client) WorkspaceExists() (bool, error) {
+	ws := c.workspace()
+
+	if ws == "" {
+		return true, nil
+	}
+
+	res, err := c.httpRequest(http.MethodGet, c.rootURL()+"/workspaces"+workspacePath(ws), nil, nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("[HTTP %d] Error fetching workspace %s. Bad response from the API", res.StatusCode, ws)
+}
+
+// ensureWorkspace fails when the workspace of the client is missing from Kong,
+// unless CreateWorkspace is set and it is created
+func (c *Client) ensureWorkspace() error {
+	ws := c.workspace()
+	exists, err := c.WorkspaceExists()
+
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	if !c.CreateWorkspace {
+		return fmt.Errorf("Workspace %s doesn't exist in Kong at %s, create it with --create-workspace", ws, c.rootURL())
+	}
+
+	return c.track(KindWorkspace, ws, ActionCreate, func() (int, error) {
+		payload, err := json.Marshal(map[string]string{"name": ws})
+
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := c.httpRequest(http.MethodPost, c.rootURL()+"/workspaces", payload, nil)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating workspace %s: workspaces require Kong Enterprise", res.StatusCode, ws)
+		}
+
+		if res.StatusCode != http.StatusCreated {
+			return res.StatusCode, fmt.Errorf("[HTTP %d] Error creating workspace %s. Bad response from the API", res.StatusCode, ws)
+		}
+
+		return res.StatusCode, nil
+	})
+}
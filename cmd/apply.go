@@ -1,39 +1,328 @@
 package cmd
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/pagerinc/kongfig/api"
 	"github.com/spf13/cobra"
 )
 
 var (
-	fileVar   string
-	dryRunVar bool
+	fileVar            string
+	dryRunVar          bool
+	snapshotDirVar     string
+	disableRollbackVar bool
+	schemaDirVar       string
+	noValidateVar      bool
+	recordVar          string
+	replayVar          string
+	journalDirVar      string
+	resumeVar          string
+	lockTimeoutVar     time.Duration
+	forceUnlockVar     bool
+	autoApproveVar     bool
+	createWorkspaceVar bool
 )
 
 func init() {
 	const (
-		defaultConfig = "config.yaml"
-		configUsage   = "Filename that contains the configuration to apply"
-		defaultDryRun = false
-		dryRunUsage   = "simulate an install"
+		defaultConfig          = "config.yaml"
+		configUsage            = "Filename that contains the configuration to apply"
+		defaultDryRun          = false
+		dryRunUsage            = "simulate an install"
+		defaultSnapshotDir     = ".kongfig/snapshots"
+		snapshotDirUsage       = "Directory where the state of Kong is saved before applying"
+		defaultDisableRollback = false
+		disableRollbackUsage   = "Do not restore the snapshot when applying fails"
+		defaultSchemaDir       = ".kongfig/schemas"
+		schemaDirUsage         = "Directory where the plugin schemas of Kong are cached"
+		defaultNoValidate      = false
+		noValidateUsage        = "Do not validate plugin and credential configs against the schemas of Kong"
+		recordUsage            = "Directory where every Admin API request and response of the apply is recorded"
+		replayUsage            = "Directory of a recorded apply to replay instead of reaching Kong, failing when requests differ"
+		defaultJournalDir      = ".kongfig/journals"
+		journalDirUsage        = "Directory where the operations of the apply and their progress are recorded"
+		resumeUsage            = "Journal of an interrupted apply to resume"
+		defaultLockTimeout     = 30 * time.Minute
+		lockTimeoutUsage       = "How long the apply lock is held before another apply may take it over"
+		defaultForceUnlock     = false
+		forceUnlockUsage       = "Take the apply lock over even when another apply holds it"
+		defaultAutoApprove     = false
+		autoApproveUsage       = "Apply without asking to confirm the plan on a terminal"
+		defaultCreateWorkspace = false
+		createWorkspaceUsage   = "Create the workspace of Kong Enterprise when it doesn't exist"
 	)
 
 	applyCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
 	applyCmd.Flags().BoolVar(&dryRunVar, "dry-run", defaultDryRun, dryRunUsage)
+	applyCmd.Flags().StringVar(&snapshotDirVar, "snapshot-dir", defaultSnapshotDir, snapshotDirUsage)
+	applyCmd.Flags().BoolVar(&disableRollbackVar, "no-rollback", defaultDisableRollback, disableRollbackUsage)
+	applyCmd.Flags().StringVar(&schemaDirVar, "schema-dir", defaultSchemaDir, schemaDirUsage)
+	applyCmd.Flags().BoolVar(&noValidateVar, "no-validate", defaultNoValidate, noValidateUsage)
+	applyCmd.Flags().StringVar(&recordVar, "record", "", recordUsage)
+	applyCmd.Flags().StringVar(&replayVar, "replay", "", replayUsage)
+	applyCmd.Flags().StringVar(&journalDirVar, "journal-dir", defaultJournalDir, journalDirUsage)
+	applyCmd.Flags().StringVar(&resumeVar, "resume", "", resumeUsage)
+	applyCmd.Flags().DurationVar(&lockTimeoutVar, "lock-timeout", defaultLockTimeout, lockTimeoutUsage)
+	applyCmd.Flags().BoolVar(&forceUnlockVar, "force-unlock", defaultForceUnlock, forceUnlockUsage)
+	applyCmd.Flags().BoolVar(&autoApproveVar, "auto-approve", defaultAutoApprove, autoApproveUsage)
+	applyCmd.Flags().BoolVar(&createWorkspaceVar, "create-workspace", defaultCreateWorkspace, createWorkspaceUsage)
+	addDeletionFlags(applyCmd)
+	addSelectorFlags(applyCmd)
+	addFanOutFlags(applyCmd)
 	kongfig.AddCommand(applyCmd)
 }
 
 var applyCmd = &cobra.Command{
-	Use:   "apply",
+	Use:   "apply [plan file]",
 	Short: "Apply a configuration to a Kong instance",
-	Long:  `Use apply to restore your settings into an existing Kong instance.`,
+	Args:  cobra.MaximumNArgs(1),
+	Long: `Use apply to restore your settings into an existing Kong instance.
+
+Plugin and credential configs are first validated against the schemas of Kong.
+The current state of Kong is saved to a snapshot before anything is changed.
+If applying fails, Kong is rolled back to that snapshot.
+
+On a terminal, the planned operations are printed and must be confirmed unless
+--auto-approve is given. Given a plan file written by plan --out, exactly the
+operations of that plan are applied, failing when Kong changed since.
+
+Concurrent applies are prevented by a lock held in Kong meanwhile, marked by
+the kongfig-lock consumer. A lock left behind by an apply that died expires
+after --lock-timeout, or can be taken over at once with --force-unlock.
+
+The planned operations and their progress are recorded in a journal. When an
+apply is interrupted, --resume continues it from the last completed operation.
+
+--only, --select-tag and --service restrict the apply to some entities, the
+others being neither changed nor deleted. Routes of selected services, plugins
+of selected routes and services and credentials of selected consumers are
+selected along with them, as they are recreated together.
+
+Entities of Kong missing from the config are deleted, unless --prune=false is
+given or they match a --protect pattern. The entities about to be deleted are
+listed first, and --allow-delete fails the apply when there are more of them.
+
+With Kong Enterprise, the entities are applied to the workspace of the config
+or of --workspace, which --create-workspace creates when it doesn't exist.
+
+When the config lists clusters, it is applied to each of them, or to the ones
+chosen with --cluster, and a summary per cluster is printed. --parallel applies
+to every cluster at once and --canary stops at the first cluster that fails.
+
+--record saves every request made to the Admin API with its response, and
+--replay serves them back to check that an apply makes the same requests.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := api.NewClient(fileVar)
+		clients, err := newClients(fileVar)
 
 		if err != nil {
 			return err
 		}
-		err = client.ApplyConfig()
+
+		reporter, err := newReporter()
+
+		if err != nil {
+			return err
+		}
+
+		selector, err := newSelector()
+
+		if err != nil {
+			return err
+		}
+
+		policy, err := newDeletionPolicy()
+
+		if err != nil {
+			return err
+		}
+
+		if recordVar != "" && replayVar != "" {
+			return fmt.Errorf("--record and --replay can't be used together")
+		}
+
+		var plan *api.Plan
+
+		if len(args) == 1 {
+			if resumeVar != "" {
+				return fmt.Errorf("A plan file and --resume can't be used together")
+			}
+
+			if plan, err = api.LoadPlan(args[0]); err != nil {
+				return err
+			}
+		}
+
+		if len(clients) > 1 {
+			if plan != nil || resumeVar != "" || recordVar != "" || replayVar != "" {
+				return fmt.Errorf("Plan files, --resume, --record and --replay apply to a single cluster, choose one with --cluster")
+			}
+
+			reporter = api.SyncReporter(reporter)
+		}
+
+		// Clusters applied in parallel ask to confirm their plan one at a time
+		var approving sync.Mutex
+
+		for _, client := range clients {
+			client := client
+
+			client.Selector = selector
+			client.DeletionPolicy = policy
+			client.SnapshotDir = clusterDir(snapshotDirVar, client)
+			client.DisableRollback = disableRollbackVar
+			client.JournalDir = clusterDir(journalDirVar, client)
+			client.LockTimeout = lockTimeoutVar
+			client.ForceUnlock = forceUnlockVar
+			client.CreateWorkspace = createWorkspaceVar
+			client.SchemaDir = schemaDirVar
+			client.DisableValidation = noValidateVar
+			client.Reporter = reporter
+
+			if !autoApproveVar && replayVar == "" && isTerminal(os.Stdin) {
+				client.Approve = func(plan *api.Plan) (bool, error) {
+					approving.Lock()
+					defer approving.Unlock()
+
+					if client.Cluster != "" {
+						fmt.Fprintf(os.Stderr, "Cluster %s (%s):\n", client.Cluster, client.BaseURL)
+					}
+
+					return approvePlan(plan)
+				}
+			}
+		}
+
+		if len(clients) > 1 {
+			results := newFanOut().Run(clients, func(c *api.Client) (string, error) {
+				return "applied", c.ApplyConfig()
+			})
+
+			err = reporter.Close()
+			api.WriteClusterSummary(os.Stderr, results)
+
+			if ferr := clusterFailures("Applying", results); ferr != nil {
+				return ferr
+			}
+
+			return err
+		}
+
+		client := clients[0]
+
+		if recordVar != "" || replayVar != "" {
+			// The lock records who applies and when, which would differ on every replay
+			client.DisableLock = true
+
+			// Cached schemas would leave their requests out of the cassette
+			if !cmd.Flags().Changed("schema-dir") {
+				dir, err := ioutil.TempDir("", "kongfig-schemas")
+
+				if err != nil {
+					return err
+				}
+
+				defer os.RemoveAll(dir)
+				client.SchemaDir = filepath.Join(dir, "schemas")
+			}
+		}
+
+		cassette := &api.Cassette{}
+		var replay *api.ReplayTransport
+
+		switch {
+		case recordVar != "":
+			client.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+				return api.NewRecordingTransport(base, cassette)
+			})
+		case replayVar != "":
+			if cassette, err = api.LoadCassette(replayVar); err != nil {
+				return err
+			}
+
+			replay = api.NewReplayTransport(cassette)
+			client.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+				if traceHTTPVar {
+					return api.NewTracingTransport(replay, client.Logger)
+				}
+
+				return replay
+			})
+		}
+
+		switch {
+		case resumeVar != "":
+			err = client.Resume(resumeVar)
+		case plan != nil:
+			err = client.ApplyPlan(plan)
+		default:
+			err = client.ApplyConfig()
+		}
+
+		if cerr := reporter.Close(); err == nil {
+			err = cerr
+		}
+
+		if recordVar != "" {
+			path, serr := cassette.Save(recordVar)
+
+			if serr != nil {
+				return serr
+			}
+
+			client.Logger.Infof("Recorded %d request(s) to %s", len(cassette.Interactions), path)
+		}
+
+		if replay != nil {
+			// A request differing from the cassette explains why applying failed
+			if verr := replay.Verify(); verr != nil {
+				return verr
+			}
+
+			client.Logger.Infof("Replayed the %d request(s) of %s", len(cassette.Interactions), replayVar)
+		}
+
 		return err
 	},
 }
+
+// approvePlan prints a plan and asks to confirm it on the terminal
+func approvePlan(plan *api.Plan) (bool, error) {
+	plan.WriteText(os.Stderr)
+
+	if plan.Changes() == 0 {
+		return true, nil
+	}
+
+	fmt.Fprint(os.Stderr, "\nDo you want to apply this plan? Only 'yes' will be accepted: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	return strings.TrimSpace(answer) == "yes", nil
+}
+
+// isTerminal tells whether f is an interactive terminal rather than a pipe, a
+// file or the null device, which is a character device as well
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	null, err := os.Stat(os.DevNull)
+
+	return err != nil || !os.SameFile(info, null)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+// Formats supported by the convert command
+const (
+	formatKongfig     = "kongfig"
+	formatDeclarative = "declarative"
+)
+
+var (
+	fromVar string
+	toVar   string
+)
+
+func init() {
+	const (
+		defaultConfig = "config.yaml"
+		configUsage   = "Filename that contains the configuration to convert"
+		defaultFrom   = formatKongfig
+		fromUsage     = "Format of the input file, one of: kongfig, declarative"
+		defaultTo     = formatDeclarative
+		toUsage       = "Format to convert to, one of: kongfig, declarative"
+	)
+
+	convertCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	convertCmd.Flags().StringVar(&fromVar, "from", defaultFrom, fromUsage)
+	convertCmd.Flags().StringVar(&toVar, "to", defaultTo, toUsage)
+	kongfig.AddCommand(convertCmd)
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert between kongfig and Kong declarative configurations",
+	Long: `Use convert to translate a kongfig configuration into Kong's declarative
+format (_format_version), as used by DB-less mode and decK, or the reverse.
+
+The result is printed to stdout, and a warning is printed to stderr for
+anything that can't be represented in the target format.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var warnings []string
+
+		switch {
+		case fromVar == formatKongfig && toVar == formatDeclarative:
+			config, err := api.LoadConfig(fileVar)
+
+			if err != nil {
+				return err
+			}
+
+			d, w, err := api.ToDeclarative(config)
+
+			if err != nil {
+				return err
+			}
+
+			if err := api.WriteDeclarative(os.Stdout, outputVar, d); err != nil {
+				return err
+			}
+
+			warnings = w
+		case fromVar == formatDeclarative && toVar == formatKongfig:
+			data, err := ioutil.ReadFile(fileVar)
+
+			if err != nil {
+				return err
+			}
+
+			config, w, err := api.FromDeclarative(data)
+
+			if err != nil {
+				return err
+			}
+
+			if err := api.WriteConfig(os.Stdout, outputVar, config); err != nil {
+				return err
+			}
+
+			warnings = w
+		default:
+			return fmt.Errorf("Converting from %s to %s is not supported", fromVar, toVar)
+		}
+
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+
+		return nil
+	},
+}
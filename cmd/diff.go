@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	const (
+		defaultConfig = "config.yaml"
+		configUsage   = "Filename that contains the configuration to compare"
+	)
+
+	diffCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	addSelectorFlags(diffCmd)
+	addFanOutFlags(diffCmd)
+	kongfig.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:     "diff",
+	Aliases: []string{"check"},
+	Short:   "Compare a configuration against a Kong instance",
+	Long: `Use diff to detect drift between a configuration and the live state of Kong.
+
+--only, --select-tag and --service restrict the comparison to some entities.
+With Kong Enterprise, only the workspace of the config or of --workspace is
+compared.
+
+When the config lists clusters, each of them is compared, or the ones chosen
+with --cluster, and a summary per cluster is printed. --parallel compares every
+cluster at once and --canary stops at the first cluster that fails.
+
+Exit codes:
+  0  Kong is in sync with the configuration
+  1  Drift detected
+  2  Error`,
+	// Drift is reported through the exit code, not as a usage error
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reporter, err := newReporter()
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		clients, err := newClients(fileVar)
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		selector, err := newSelector()
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		for _, client := range clients {
+			client.Selector = selector
+		}
+
+		if len(clients) > 1 {
+			return diffClusters(clients, reporter)
+		}
+
+		diff, err := clients[0].Diff()
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		switch outputVar {
+		case api.OutputText:
+			diff.WriteText(os.Stdout)
+		case api.OutputJSON:
+			err = diff.WriteJSON(os.Stdout)
+		default:
+			for _, e := range diff.Events() {
+				reporter.Report(e)
+			}
+
+			err = reporter.Close()
+		}
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		if !diff.InSync() {
+			return &exitCodeError{exitDrift, nil}
+		}
+
+		return nil
+	},
+}
+
+// diffClusters compares the config against several clusters, writing their
+// diffs in order once they are all compared, followed by a summary per cluster
+func diffClusters(clients []*api.Client, reporter api.Reporter) error {
+	diffs := make([]*api.Diff, len(clients))
+	results := newFanOut().Run(clients, func(c *api.Client) (string, error) {
+		diff, err := c.Diff()
+
+		if err != nil {
+			return "", err
+		}
+
+		for i := range clients {
+			if clients[i] == c {
+				diffs[i] = diff
+			}
+		}
+
+		if diff.InSync() {
+			return "in sync", nil
+		}
+
+		return fmt.Sprintf("%d change(s)", len(diff.Changes)), nil
+	})
+
+	var err error
+	drift := false
+
+	switch outputVar {
+	case api.OutputText:
+		for i, diff := range diffs {
+			if diff != nil {
+				fmt.Fprintf(os.Stdout, "Cluster %s (%s):\n", clients[i].Cluster, clients[i].BaseURL)
+				diff.WriteText(os.Stdout)
+				fmt.Fprintln(os.Stdout)
+			}
+		}
+	case api.OutputJSON:
+		report := []clusterDiff{}
+
+		for i, r := range results {
+			cd := clusterDiff{ClusterResult: r, Changes: []api.Change{}}
+
+			if diffs[i] != nil {
+				cd.InSync = diffs[i].InSync()
+				cd.Changes = append(cd.Changes, diffs[i].Changes...)
+			}
+
+			report = append(report, cd)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	default:
+		for i, diff := range diffs {
+			if diff == nil {
+				continue
+			}
+
+			for _, e := range diff.Events() {
+				e.Cluster = clients[i].Cluster
+				reporter.Report(e)
+			}
+		}
+
+		err = reporter.Close()
+	}
+
+	for _, diff := range diffs {
+		drift = drift || (diff != nil && !diff.InSync())
+	}
+
+	api.WriteClusterSummary(os.Stderr, results)
+
+	if ferr := clusterFailures("Comparing", results); ferr != nil {
+		return &exitCodeError{exitError, ferr}
+	}
+
+	if err != nil {
+		return &exitCodeError{exitError, err}
+	}
+
+	if drift {
+		return &exitCodeError{exitDrift, nil}
+	}
+
+	return nil
+}
+
+// clusterDiff is the diff of a cluster in the JSON output of diff
+type clusterDiff struct {
+	api.ClusterResult
+	InSync  bool         `json:"in_sync"`
+	Changes []api.Change `json:"changes"`
+}
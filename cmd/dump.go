@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	const (
+		defaultConfig = "config.yaml"
+		configUsage   = "Filename that contains the Kong connection settings"
+	)
+
+	dumpCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	kongfig.AddCommand(dumpCmd)
+}
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Export the configuration of a Kong instance",
+	Long: `Use dump to export the live state of Kong as a configuration.
+
+The text output is a YAML configuration that can be applied back, json has the
+same structure and jsonl prints one entity per line.
+
+With Kong Enterprise, only the entities of the workspace of the config or of
+--workspace are dumped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(fileVar)
+
+		if err != nil {
+			return err
+		}
+
+		config, err := client.Dump()
+
+		if err != nil {
+			return err
+		}
+
+		return api.WriteConfig(os.Stdout, outputVar, config)
+	},
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serviceVar string
+	urlVar     string
+	mergeVar   string
+)
+
+func init() {
+	const (
+		serviceUsage = "Name of the generated service, defaults to x-kong-name or the title of the spec"
+		urlUsage     = "URL of the upstream, defaults to the first server of the spec"
+		mergeUsage   = "Config file to merge the generated entities into"
+	)
+
+	importOpenAPICmd.Flags().StringVar(&serviceVar, "service", "", serviceUsage)
+	importOpenAPICmd.Flags().StringVar(&urlVar, "url", "", urlUsage)
+	importOpenAPICmd.Flags().StringVar(&mergeVar, "merge", "", mergeUsage)
+	importCmd.AddCommand(importOpenAPICmd)
+	kongfig.AddCommand(importCmd)
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Generate configuration from other sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var importOpenAPICmd = &cobra.Command{
+	Use:   "openapi [spec]",
+	Short: "Generate a service and its routes from an OpenAPI 3 specification",
+	Long: `Use import openapi to generate a service, its routes and plugins from an
+OpenAPI 3 specification.
+
+Paths are converted to anchored Kong regex paths, with a named capture group per
+path parameter. The operations of a path are grouped into a single route, unless
+they declare different plugins. x-kong-plugin-<name> extensions of the spec, a
+path or an operation become plugins, and x-kong-name, x-kong-service-defaults and
+x-kong-route-defaults customise the generated entities.
+
+The generated config is printed to stdout, merged into the file given by --merge
+if any. Entities with the same name are replaced.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := ioutil.ReadFile(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		config, err := api.FromOpenAPI(data, api.OpenAPIOptions{Service: serviceVar, URL: urlVar})
+
+		if err != nil {
+			return err
+		}
+
+		if mergeVar != "" {
+			// Environment variables are kept as is, so they aren't written out
+			existing, err := ioutil.ReadFile(mergeVar)
+
+			if err != nil {
+				return err
+			}
+
+			// The file is merged as written, keeping its nested entities and templates
+			merged, err := api.ParseRawConfig(existing)
+
+			if err != nil {
+				return err
+			}
+
+			api.MergeConfig(merged, config)
+			config = merged
+		}
+
+		return api.WriteConfig(os.Stdout, outputVar, config)
+	},
+}
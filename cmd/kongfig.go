@@ -3,10 +3,51 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/pagerinc/kongfig/api"
 	"github.com/spf13/cobra"
 )
 
+var (
+	outputVar        string
+	verboseVar       bool
+	quietVar         bool
+	traceHTTPVar     bool
+	clusterVar       []string
+	workspaceVar     string
+	parallelVar      bool
+	canaryVar        bool
+	onlyVar          []string
+	selectTagVar     []string
+	selectServiceVar []string
+	pruneVar         bool
+	protectVar       []string
+	allowDeleteVar   int
+)
+
+func init() {
+	const (
+		defaultOutput    = "text"
+		outputUsage      = "Output format, one of: text, json, jsonl, junit"
+		defaultVerbose   = false
+		verboseUsage     = "Log debug messages"
+		defaultQuiet     = false
+		quietUsage       = "Only log errors"
+		defaultTraceHTTP = false
+		traceHTTPUsage   = "Log every Admin API request and response, with secrets masked"
+		clusterUsage     = "Only reach this cluster of the config, every cluster by default for apply and diff"
+		workspaceUsage   = "Workspace of Kong Enterprise to reach, instead of the workspace of the config"
+	)
+
+	kongfig.PersistentFlags().StringVarP(&outputVar, "output", "o", defaultOutput, outputUsage)
+	kongfig.PersistentFlags().BoolVarP(&verboseVar, "verbose", "v", defaultVerbose, verboseUsage)
+	kongfig.PersistentFlags().BoolVarP(&quietVar, "quiet", "q", defaultQuiet, quietUsage)
+	kongfig.PersistentFlags().BoolVar(&traceHTTPVar, "trace-http", defaultTraceHTTP, traceHTTPUsage)
+	kongfig.PersistentFlags().StringSliceVar(&clusterVar, "cluster", nil, clusterUsage)
+	kongfig.PersistentFlags().StringVar(&workspaceVar, "workspace", "", workspaceUsage)
+}
+
 var kongfig = &cobra.Command{
 	Use:   "kongfig",
 	Short: "Kongfig is a configuration management tool for Kong API gateway",
@@ -18,10 +59,198 @@ Find more information at https://github.com/pagerinc/kongfig`,
 	},
 }
 
+// Exit codes of the diff and validate commands, so they can be used in scheduled checks
+const (
+	exitInSync = 0
+	exitDrift  = 1
+	exitIssues = 1
+	exitError  = 2
+)
+
+// exitCodeError makes kongfig exit with a specific status code.
+// A nil err exits silently
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+
+	return e.err.Error()
+}
+
+// newClient returns a client for the config at path, logging to stderr with
+// the requested verbosity. A config with clusters must have one chosen with --cluster
+func newClient(path string) (*api.Client, error) {
+	clients, err := newClients(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clients) > 1 {
+		return nil, fmt.Errorf("The config has %d clusters, choose one with --cluster", len(clients))
+	}
+
+	return clients[0], nil
+}
+
+// newClients returns a client for every cluster of the config at path chosen
+// with --cluster, or for its host when it has no clusters
+func newClients(path string) ([]*api.Client, error) {
+	// Traced requests are logged as info, which --quiet hides
+	if quietVar && traceHTTPVar {
+		return nil, fmt.Errorf("--quiet and --trace-http can't be used together")
+	}
+
+	config, err := api.LoadConfig(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if workspaceVar != "" {
+		config.Workspace = workspaceVar
+	}
+
+	clients, err := api.NewClusterClients(config, clusterVar)
+
+	if err != nil {
+		return nil, err
+	}
+
+	level := api.LevelInfo
+
+	if verboseVar {
+		level = api.LevelDebug
+	}
+
+	if quietVar {
+		level = api.LevelError
+	}
+
+	for _, client := range clients {
+		client.Logger = api.NewLogger(os.Stderr, level)
+
+		if client.Cluster != "" {
+			client.Logger = api.PrefixLogger(client.Logger, "["+client.Cluster+"] ")
+		}
+
+		if traceHTTPVar {
+			client.TraceHTTP()
+		}
+	}
+
+	return clients, nil
+}
+
+// clusterDir returns the subdirectory of dir for the cluster a client reaches,
+// dir itself when the config has a single host
+func clusterDir(dir string, client *api.Client) string {
+	if client.Cluster == "" {
+		return dir
+	}
+
+	return filepath.Join(dir, client.Cluster)
+}
+
+// addFanOutFlags adds the flags controlling how a command runs against several clusters
+func addFanOutFlags(cmd *cobra.Command) {
+	const (
+		defaultParallel = false
+		parallelUsage   = "Run against every cluster at once instead of one after the other"
+		defaultCanary   = false
+		canaryUsage     = "Stop at the first cluster that fails, skipping the others. With --parallel, the first cluster goes alone before the others"
+	)
+
+	cmd.Flags().BoolVar(&parallelVar, "parallel", defaultParallel, parallelUsage)
+	cmd.Flags().BoolVar(&canaryVar, "canary", defaultCanary, canaryUsage)
+}
+
+// newFanOut returns how to run a command against several clusters, as set with the fan-out flags
+func newFanOut() *api.FanOut {
+	return &api.FanOut{Parallel: parallelVar, Canary: canaryVar}
+}
+
+// clusterFailures returns an error counting the clusters a command failed on or skipped, if any
+func clusterFailures(action string, results []api.ClusterResult) error {
+	failed, skipped := 0, 0
+
+	for _, r := range results {
+		switch r.Status {
+		case api.StatusFailed:
+			failed++
+		case api.StatusSkipped:
+			skipped++
+		}
+	}
+
+	if failed == 0 && skipped == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%s failed on %d of %d cluster(s), %d skipped", action, failed, len(results), skipped)
+}
+
+// addSelectorFlags adds the flags restricting a command to some entities of the config and of Kong
+func addSelectorFlags(cmd *cobra.Command) {
+	const (
+		onlyUsage      = "Only select entities of these types: services, routes, plugins, consumers, credentials"
+		selectTagUsage = "Only select entities having this tag, entities must have every given tag"
+		serviceUsage   = "Only select this service along with its routes and plugins"
+	)
+
+	cmd.Flags().StringSliceVar(&onlyVar, "only", nil, onlyUsage)
+	cmd.Flags().StringSliceVar(&selectTagVar, "select-tag", nil, selectTagUsage)
+	cmd.Flags().StringSliceVar(&selectServiceVar, "service", nil, serviceUsage)
+}
+
+// newSelector returns the selector of the entities chosen with the selector flags
+func newSelector() (*api.Selector, error) {
+	return api.NewSelector(onlyVar, selectTagVar, selectServiceVar)
+}
+
+// addDeletionFlags adds the flags controlling which entities missing from the config are deleted
+func addDeletionFlags(cmd *cobra.Command) {
+	const (
+		defaultPrune       = true
+		pruneUsage         = "Delete the entities of Kong missing from the config, --prune=false only creates and updates entities"
+		protectUsage       = "Never delete the entities matching this pattern, as type:glob or glob, eg: service:legacy-*"
+		defaultAllowDelete = -1
+		allowDeleteUsage   = "Fail before changing anything when more entities would be deleted, -1 for no limit"
+	)
+
+	cmd.Flags().BoolVar(&pruneVar, "prune", defaultPrune, pruneUsage)
+	cmd.Flags().StringSliceVar(&protectVar, "protect", nil, protectUsage)
+	cmd.Flags().IntVar(&allowDeleteVar, "allow-delete", defaultAllowDelete, allowDeleteUsage)
+}
+
+// newDeletionPolicy returns the deletion policy set with the deletion flags
+func newDeletionPolicy() (*api.DeletionPolicy, error) {
+	return api.NewDeletionPolicy(pruneVar, protectVar, allowDeleteVar)
+}
+
+// newReporter returns a reporter writing events to stdout in the requested output format
+func newReporter() (api.Reporter, error) {
+	return api.NewReporter(outputVar, os.Stdout)
+}
+
 // Execute runs the konfig cli
 func Execute() {
 	if err := kongfig.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+		code := 1
+
+		if e, ok := err.(*exitCodeError); ok {
+			code, err = e.code, e.err
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outVar string
+)
+
+func init() {
+	const (
+		defaultConfig     = "config.yaml"
+		configUsage       = "Filename that contains the configuration to plan"
+		outUsage          = "File where the plan is written, to apply it later with apply <file>"
+		defaultSchemaDir  = ".kongfig/schemas"
+		schemaDirUsage    = "Directory where the plugin schemas of Kong are cached"
+		defaultNoValidate = false
+		noValidateUsage   = "Do not validate plugin and credential configs against the schemas of Kong"
+	)
+
+	planCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	planCmd.Flags().StringVar(&outVar, "out", "", outUsage)
+	planCmd.Flags().StringVar(&schemaDirVar, "schema-dir", defaultSchemaDir, schemaDirUsage)
+	planCmd.Flags().BoolVar(&noValidateVar, "no-validate", defaultNoValidate, noValidateUsage)
+	addDeletionFlags(planCmd)
+	addSelectorFlags(planCmd)
+	kongfig.AddCommand(planCmd)
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the operations applying a configuration would make",
+	Long: `Use plan to print the operations apply would make, in order, without
+changing anything.
+
+With --out, the plan is written to a file. apply <file> then makes exactly
+these operations, failing when Kong changed since the plan was made:
+
+  kongfig plan -f config.yaml --out plan.json
+  kongfig apply -f config.yaml plan.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(fileVar)
+
+		if err != nil {
+			return err
+		}
+
+		if client.Selector, err = newSelector(); err != nil {
+			return err
+		}
+
+		if client.DeletionPolicy, err = newDeletionPolicy(); err != nil {
+			return err
+		}
+
+		client.SchemaDir = schemaDirVar
+		client.DisableValidation = noValidateVar
+
+		plan, err := client.Plan()
+
+		if err != nil {
+			return err
+		}
+
+		switch outputVar {
+		case api.OutputText:
+			plan.WriteText(os.Stdout)
+		case api.OutputJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			if err := enc.Encode(plan); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Output format %s is not supported when planning", outputVar)
+		}
+
+		if outVar != "" {
+			if err := plan.Save(outVar); err != nil {
+				return err
+			}
+
+			client.Logger.Infof("Plan saved to %s, apply it with `kongfig apply -f %s %s`", outVar, fileVar, outVar)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var schemasOutputVar string
+
+func init() {
+	const (
+		defaultConfig    = "config.yaml"
+		configUsage      = "Filename that contains the configuration of the Kong instance"
+		defaultOutputDir = "api/schemas"
+		outputDirUsage   = "Directory where the schemas are stored, per Kong version"
+	)
+
+	refreshSchemasCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	refreshSchemasCmd.Flags().StringVar(&schemasOutputVar, "dir", defaultOutputDir, outputDirUsage)
+	kongfig.AddCommand(refreshSchemasCmd)
+}
+
+var refreshSchemasCmd = &cobra.Command{
+	Use:   "refresh-schemas",
+	Short: "Store the plugin schemas of a Kong instance for offline validation",
+	Long: `Use refresh-schemas to fetch the schemas of every plugin enabled in a Kong
+instance, and of credentials, into a directory named after its major and minor
+version, eg: api/schemas/1.1, replacing the schemas stored for that version.
+
+From the kongfig repository, run go generate ./api afterwards to bundle them
+into the kongfig binary.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(fileVar)
+
+		if err != nil {
+			return err
+		}
+
+		dir, err := client.RefreshSchemas(schemasOutputVar)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Schemas stored in %s\n", dir)
+
+		return nil
+	},
+}
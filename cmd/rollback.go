@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotVar string
+)
+
+func init() {
+	const (
+		defaultConfig = "config.yaml"
+		configUsage   = "Filename that contains the Kong connection settings"
+		snapshotUsage = "Snapshot file to restore, as saved by apply"
+	)
+
+	rollbackCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	rollbackCmd.Flags().StringVar(&snapshotVar, "snapshot", "", snapshotUsage)
+	rollbackCmd.MarkFlagRequired("snapshot")
+	kongfig.AddCommand(rollbackCmd)
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore a Kong instance to a snapshot",
+	Long: `Use rollback to restore Kong to exactly the state saved in a snapshot,
+including the original entity IDs. Anything not in the snapshot is deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snapshot, err := api.LoadSnapshot(snapshotVar)
+
+		if err != nil {
+			return err
+		}
+
+		client, err := newClient(fileVar)
+
+		if err != nil {
+			return err
+		}
+
+		reporter, err := newReporter()
+
+		if err != nil {
+			return err
+		}
+
+		client.Reporter = reporter
+		err = client.Restore(snapshot)
+
+		if cerr := reporter.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	},
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	methodVar   string
+	hostVar     string
+	pathVar     string
+	protocolVar string
+	headersVar  []string
+)
+
+func init() {
+	const (
+		defaultConfig   = "config.yaml"
+		configUsage     = "Filename that contains the configuration to test"
+		defaultMethod   = "GET"
+		methodUsage     = "HTTP method of the request"
+		hostUsage       = "Host header of the request"
+		defaultPath     = "/"
+		pathUsage       = "Path of the request"
+		defaultProtocol = "http"
+		protocolUsage   = "Protocol of the request, one of: http, https"
+		headerUsage     = "Header of the request as name=value, can be repeated"
+	)
+
+	routeTestCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	routeTestCmd.Flags().StringVar(&methodVar, "method", defaultMethod, methodUsage)
+	routeTestCmd.Flags().StringVar(&hostVar, "host", "", hostUsage)
+	routeTestCmd.Flags().StringVar(&pathVar, "path", defaultPath, pathUsage)
+	routeTestCmd.Flags().StringVar(&protocolVar, "protocol", defaultProtocol, protocolUsage)
+	routeTestCmd.Flags().StringArrayVar(&headersVar, "header", nil, headerUsage)
+	kongfig.AddCommand(routeTestCmd)
+}
+
+var routeTestCmd = &cobra.Command{
+	Use:   "route-test",
+	Short: "Show which route of a configuration matches a request",
+	Long: `Use route-test to find, without deploying, the route Kong would proxy a
+request through, following its router precedence rules (hosts, headers,
+methods, regex_priority and longest path), and the resulting upstream request.
+
+Exits with an error when no route matches.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := api.LoadConfig(fileVar)
+
+		if err != nil {
+			return err
+		}
+
+		req := api.Request{
+			Protocol: protocolVar,
+			Method:   strings.ToUpper(methodVar),
+			Host:     hostVar,
+			Path:     pathVar,
+			Headers:  make(map[string][]string),
+		}
+
+		for _, h := range headersVar {
+			parts := strings.SplitN(h, "=", 2)
+
+			if len(parts) != 2 {
+				return fmt.Errorf("Invalid header %q, expected name=value", h)
+			}
+
+			req.Headers[parts[0]] = append(req.Headers[parts[0]], parts[1])
+		}
+
+		match, err := api.MatchRoute(config, req)
+
+		if err != nil {
+			return err
+		}
+
+		if match == nil {
+			return fmt.Errorf("No route matches %s %s://%s%s", req.Method, req.Protocol, req.Host, req.Path)
+		}
+
+		if outputVar == api.OutputJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(match)
+		}
+
+		fmt.Printf("Route:         %s (service %s)\n", match.Route, match.Service)
+
+		if match.MatchedHost != "" {
+			fmt.Printf("Matched host:  %s\n", match.MatchedHost)
+		}
+
+		if match.MatchedPath != "" {
+			kind := "prefix"
+
+			if match.RegexPath {
+				kind = "regex"
+			}
+
+			fmt.Printf("Matched path:  %s (%s)\n", match.MatchedPath, kind)
+		}
+
+		names := []string{}
+
+		for name := range match.Captures {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("Capture:       %s=%s\n", name, match.Captures[name])
+		}
+
+		fmt.Printf("Upstream URL:  %s\n", match.UpstreamURL)
+		fmt.Printf("Upstream host: %s\n", match.UpstreamHost)
+
+		return nil
+	},
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	kongfig.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	Long: `Use schema to generate the JSON Schema of the configuration file, for editors
+to provide autocompletion and validation:
+
+  kongfig schema > kongfig.schema.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(api.ConfigSchema())
+	},
+}
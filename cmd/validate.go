@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pagerinc/kongfig/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	strictVar      bool
+	onlineVar      bool
+	kongVersionVar string
+)
+
+func init() {
+	const (
+		defaultConfig = "config.yaml"
+		configUsage   = "Filename that contains the configuration to validate"
+		strictUsage   = "Fail on warnings too"
+		onlineUsage   = "Fetch the plugin schemas from the Kong instance of the configuration"
+		schemaDir     = ".kongfig/schemas"
+		schemaUsage   = "Directory where the plugin schemas of Kong are cached"
+		versionUsage  = "Kong version of the cached schemas, defaults to the version of the configuration"
+	)
+
+	validateCmd.Flags().StringVarP(&fileVar, "file", "f", defaultConfig, configUsage)
+	validateCmd.Flags().BoolVar(&strictVar, "strict", false, strictUsage)
+	validateCmd.Flags().BoolVar(&onlineVar, "online", false, onlineUsage)
+	validateCmd.Flags().StringVar(&schemaDirVar, "schema-dir", schemaDir, schemaUsage)
+	validateCmd.Flags().StringVar(&kongVersionVar, "kong-version", "", versionUsage)
+	kongfig.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a configuration for mistakes without contacting Kong",
+	Long: `Use validate to lint the routes of a configuration: invalid regex paths,
+routes applied to unknown services, routes with identical matchers, regex paths
+shadowed by prefix paths, overlapping regex paths with the same priority and
+plain http routes to https services.
+
+Plugin and credential configs are validated against the schemas of Kong, either
+fetched with --online, cached in --schema-dir by previous runs of apply or
+validate --online, or bundled with kongfig for core plugins of the Kong version.
+Plugins without a schema are not validated, and a missing-schemas warning is
+reported when no schema is available for the Kong version.
+
+A rule can be suppressed for a route with a comment on the line before it or
+on any of its lines:
+
+  # kongfig-lint:ignore shadowed-regex,ambiguous-priority
+
+Exit codes:
+  0  No errors (or warnings, with --strict)
+  1  Issues found
+  2  Error`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schemas, version, err := schemaSource()
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		validation, err := api.ValidateFile(fileVar, schemas)
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		if schemas == nil {
+			validation.Issues = append(validation.Issues, api.Issue{
+				Rule:     api.RuleMissingSchemas,
+				Severity: api.SeverityWarning,
+				Kind:     "version",
+				Name:     version,
+				Message:  "kongfig has no plugin schemas for this Kong version, plugin and credential configs were not validated. Run validate --online to fetch and cache them",
+			})
+		}
+
+		switch outputVar {
+		case api.OutputText:
+			validation.WriteText(os.Stdout)
+		case api.OutputJSON:
+			err = validation.WriteJSON(os.Stdout)
+		default:
+			err = fmt.Errorf("Output format %s is not supported when validating a config", outputVar)
+		}
+
+		if err != nil {
+			return &exitCodeError{exitError, err}
+		}
+
+		if validation.Errors() > 0 || (strictVar && validation.Warnings() > 0) {
+			return &exitCodeError{exitIssues, nil}
+		}
+
+		return nil
+	},
+}
+
+// schemaSource returns the plugin schemas of the live Kong with --online, or
+// the cached and bundled ones of the Kong version otherwise, along with that
+// version. It returns no schemas when neither are available for the version
+func schemaSource() (api.SchemaSource, string, error) {
+	if onlineVar {
+		client, err := newClient(fileVar)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		client.SchemaDir = schemaDirVar
+		schemas, err := client.Schemas()
+
+		return schemas, "", err
+	}
+
+	version := kongVersionVar
+
+	if version == "" {
+		config, err := api.LoadConfig(fileVar)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		version = config.Version
+	}
+
+	cache := api.SchemaVersionDir(schemaDirVar, version)
+	bundled := api.BundledSchemas(version)
+
+	if _, err := os.Stat(cache); os.IsNotExist(err) && bundled == nil {
+		return nil, version, nil
+	}
+
+	return api.ChainSchemas(&api.SchemaCache{Dir: cache}, bundled), version, nil
+}
@@ -0,0 +1,409 @@
+package kongtest
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pagerinc/kongfig/api"
+)
+
+const testConfig = `
+services:
+  - name: api
+    url: http://api.internal:8080/v1
+    routes:
+      - name: api-root
+        paths: [/api]
+        plugins:
+          - name: cors
+            config: {origins: ["*"]}
+    plugins:
+      - name: rate-limiting
+        config: {minute: 10}
+plugins:
+  - name: correlation-id
+    target: global
+consumers:
+  - username: alice
+credentials:
+  - name: key-auth
+    target: alice
+    config: {key: secret}
+`
+
+func parseConfig(t *testing.T, data string) *api.Config {
+	t.Helper()
+
+	config, err := api.ParseConfig([]byte(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func apply(t *testing.T, c *api.Client) {
+	t.Helper()
+
+	if err := c.ApplyConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertInSync(t *testing.T, c *api.Client) {
+	t.Helper()
+
+	d, err := c.Diff()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.InSync() {
+		t.Errorf("expected Kong to be in sync with the config, got %+v", d.Changes)
+	}
+}
+
+// names returns the names of the entities of a collection, joined in the order Kong returns them
+func names(s *Server, collection string) string {
+	list := []string{}
+
+	for _, e := range s.Entities(collection) {
+		name, _ := e["name"].(string)
+
+		if username, ok := e["username"].(string); ok {
+			name = username
+		}
+
+		list = append(list, name)
+	}
+
+	return strings.Join(list, ",")
+}
+
+// ids returns the IDs of the entities of a collection, joined in the order Kong returns them
+func ids(s *Server, collection string) string {
+	list := []string{}
+
+	for _, e := range s.Entities(collection) {
+		list = append(list, e["id"].(string))
+	}
+
+	return strings.Join(list, ",")
+}
+
+func TestApply(t *testing.T) {
+	s := NewServer(Options{PageSize: 1})
+	defer s.Close()
+
+	c := s.Client(parseConfig(t, testConfig))
+	apply(t, c)
+
+	for collection, expected := range map[string]string{
+		"services":  "api",
+		"routes":    "api-root",
+		"plugins":   "correlation-id,rate-limiting,cors",
+		"consumers": "alice",
+	} {
+		if got := names(s, collection); got != expected {
+			t.Errorf("expected %s %s, got %s", collection, expected, got)
+		}
+	}
+
+	if n := len(s.Entities("key-auths")); n != 1 {
+		t.Errorf("expected 1 key-auth, got %d", n)
+	}
+
+	assertInSync(t, c)
+
+	// Services, routes and plugins are upserted in place
+	before := ids(s, "services") + ids(s, "routes") + ids(s, "plugins")
+	apply(t, c)
+
+	if after := ids(s, "services") + ids(s, "routes") + ids(s, "plugins"); after != before {
+		t.Errorf("expected entities to keep their IDs, got %s instead of %s", after, before)
+	}
+
+	changed := parseConfig(t, strings.Replace(testConfig, "[/api]", "[/api, /v1]", 1))
+	apply(t, s.Client(changed))
+
+	if after := ids(s, "routes"); !strings.Contains(before, after) {
+		t.Errorf("expected route api-root to be updated in place, got %s", after)
+	}
+
+	assertInSync(t, s.Client(changed))
+}
+
+func TestApplyLeavesConsumerPlugins(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	bob, err := s.Seed("consumers", map[string]interface{}{"username": "bob"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Seed("plugins", map[string]interface{}{"name": "rate-limiting", "consumer": map[string]interface{}{"id": bob["id"]}, "config": map[string]interface{}{"minute": 5}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := s.Client(parseConfig(t, `
+services:
+  - name: api
+    url: http://api.internal
+consumers:
+  - username: bob
+`))
+
+	apply(t, c)
+
+	if err := c.DeleteStalePlugins(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(s, "plugins"); got != "rate-limiting" {
+		t.Errorf("expected the plugin of bob to be kept, got %q", got)
+	}
+}
+
+func TestApplyRollsBackOnFailure(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	c := s.Client(parseConfig(t, testConfig))
+	apply(t, c)
+
+	changed := s.Client(parseConfig(t, strings.Replace(testConfig, "minute: 10", "minute: 20", 1)))
+	s.Fail(http.MethodPost, "/consumers/alice/key-auth", http.StatusInternalServerError)
+
+	err := changed.ApplyConfig()
+
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected the apply to fail with HTTP 500, got %v", err)
+	}
+
+	// Kong is back to the state before the apply
+	assertInSync(t, c)
+
+	if n := len(s.Entities("key-auths")); n != 1 {
+		t.Errorf("expected the key-auth of alice to be restored, got %d", n)
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	c := s.Client(parseConfig(t, testConfig))
+	c.DisableRollback = true
+	s.Fail(http.MethodPost, "/consumers/alice/key-auth", http.StatusInternalServerError)
+
+	if err := c.ApplyConfig(); err == nil {
+		t.Fatal("expected the apply to fail")
+	}
+
+	journals, _ := filepath.Glob(filepath.Join(c.JournalDir, "*.json"))
+
+	if len(journals) != 1 {
+		t.Fatalf("expected 1 journal, got %v", journals)
+	}
+
+	journal, err := api.LoadJournal(journals[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if journal.Pending() == 0 {
+		t.Fatal("expected the journal to have pending operations")
+	}
+
+	if err := c.Resume(journals[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	assertInSync(t, c)
+
+	// Resuming a completed journal does nothing
+	before := len(s.Requests())
+
+	if err := c.Resume(journals[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Requests()) - before; n != 0 {
+		t.Errorf("expected no request, got %d", n)
+	}
+
+	// Journals are only resumed with the config they were written for
+	if err := s.Client(parseConfig(t, strings.Replace(testConfig, "minute: 10", "minute: 20", 1))).Resume(journals[0]); err == nil {
+		t.Error("expected resuming with another config to fail")
+	}
+}
+
+func TestApplyLock(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	config := parseConfig(t, testConfig)
+	other := s.Client(config)
+	other.LockOwner = "someone else"
+	lock, err := other.AcquireLock()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := s.Client(config)
+	err = c.ApplyConfig()
+
+	if err == nil || !strings.Contains(err.Error(), "someone else") {
+		t.Fatalf("expected the apply to fail on the lock of someone else, got %v", err)
+	}
+
+	if n := len(s.Entities("services")); n != 0 {
+		t.Errorf("expected nothing to be applied, got %d services", n)
+	}
+
+	lock.Release()
+	apply(t, c)
+
+	if got := names(s, "consumers"); got != "alice" {
+		t.Errorf("expected the lock to be released after the apply, got consumers %s", got)
+	}
+
+	// Locks can be taken over
+	if _, err := other.AcquireLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.ForceUnlock = true
+	apply(t, c)
+	assertInSync(t, c)
+}
+
+func TestApplyDeletionPolicy(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	if _, err := s.Seed("services", map[string]interface{}{"name": "legacy-billing", "host": "billing.internal"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Seed("services", map[string]interface{}{"name": "old", "host": "old.internal"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := s.Client(parseConfig(t, testConfig))
+	policy := func(prune bool, protect []string, allowDelete int) *api.DeletionPolicy {
+		p, err := api.NewDeletionPolicy(prune, protect, allowDelete)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	c.DeletionPolicy = policy(true, nil, 0)
+	err := c.ApplyConfig()
+
+	if err == nil || !strings.Contains(err.Error(), "--allow-delete 2") {
+		t.Fatalf("expected the apply to fail on the allowed deletions, got %v", err)
+	}
+
+	if got := names(s, "services"); got != "legacy-billing,old" {
+		t.Errorf("expected nothing to be applied, got services %s", got)
+	}
+
+	c.DeletionPolicy = policy(false, nil, -1)
+	apply(t, c)
+
+	if got := names(s, "services"); got != "legacy-billing,old,api" {
+		t.Errorf("expected services missing from the config to be kept, got %s", got)
+	}
+
+	c.DeletionPolicy = policy(true, []string{"service:legacy-*"}, -1)
+	apply(t, c)
+
+	if got := names(s, "services"); got != "legacy-billing,api" {
+		t.Errorf("expected protected services to be kept, got %s", got)
+	}
+
+	c.DeletionPolicy = nil
+	apply(t, c)
+
+	if got := names(s, "services"); got != "api" {
+		t.Errorf("expected services missing from the config to be deleted, got %s", got)
+	}
+}
+
+func TestPlanListsTemplateConfig(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	config := `
+plugin_templates:
+  - name: standard-rate-limiting
+    plugin: rate-limiting
+    config: {minute: 10, policy: local}
+services:
+  - name: api
+    url: http://api.internal
+    plugins:
+      - extends: standard-rate-limiting
+        config: {hour: 100}
+`
+
+	plan, err := s.Client(parseConfig(t, config)).Plan()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := &strings.Builder{}
+	plan.WriteText(text)
+
+	if !strings.Contains(text.String(), "    config.minute: 10\n") || !strings.Contains(text.String(), "    config.hour: 100\n") {
+		t.Errorf("expected the plan to list the resolved config, got:\n%s", text)
+	}
+
+	apply(t, s.Client(parseConfig(t, config)))
+	plan, err = s.Client(parseConfig(t, strings.Replace(config, "minute: 10", "minute: 20", 1))).Plan()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text.Reset()
+	plan.WriteText(text)
+
+	if !strings.Contains(text.String(), "    config.minute: 10 => 20\n") {
+		t.Errorf("expected the plan to list the changed config, got:\n%s", text)
+	}
+}
+
+func TestSchemasCachedPerMinorVersion(t *testing.T) {
+	s := NewServer(Options{Version: "1.1.2"})
+	defer s.Close()
+
+	c := s.Client(parseConfig(t, testConfig))
+
+	if _, err := c.ValidatePlugins(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Offline validation of a config for Kong 1.1.0 finds the schemas cached from Kong 1.1.2
+	cached := &api.SchemaCache{Dir: api.SchemaVersionDir(c.SchemaDir, "1.1.0")}
+	schema, err := cached.Schema(api.KindPlugin, "cors")
+
+	if err != nil || schema == nil {
+		t.Errorf("expected the schema of cors to be cached for Kong 1.1, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package kongtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pagerinc/kongfig/api"
+)
+
+// cassetteClient returns a client of s for config as apply --record and
+// --replay set it up, without a lock and with no cached schema
+func cassetteClient(t *testing.T, s *Server, config string, dir string) *api.Client {
+	t.Helper()
+
+	c := s.Client(parseConfig(t, config))
+	c.DisableLock = true
+	c.SchemaDir = filepath.Join(dir, "schemas")
+
+	return c
+}
+
+// replay applies config through a replay of the cassette saved to dir
+func replay(t *testing.T, s *Server, config string, dir string) (*api.ReplayTransport, error) {
+	t.Helper()
+
+	cassette, err := api.LoadCassette(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay := api.NewReplayTransport(cassette)
+	schemas, err := ioutil.TempDir(dir, "replay")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cassetteClient(t, s, config, schemas)
+	c.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return replay
+	})
+
+	return replay, c.ApplyConfig()
+}
+
+func TestCassette(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "kongtest-cassette")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cassette := &api.Cassette{}
+	c := cassetteClient(t, s, testConfig, dir)
+	c.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+		return api.NewRecordingTransport(base, cassette)
+	})
+
+	apply(t, c)
+
+	if _, err := cassette.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replays never reach Kong
+	s.Reset()
+	transport, err := replay(t, s, testConfig, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transport.Verify(); err != nil {
+		t.Errorf("expected the replay to match the cassette, got %v", err)
+	}
+
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("expected no request to reach Kong, got %d", n)
+	}
+
+	transport, err = replay(t, s, strings.Replace(testConfig, "minute: 10", "minute: 20", 1), dir)
+
+	if err == nil {
+		t.Error("expected applying another config to fail")
+	}
+
+	if err := transport.Verify(); err == nil || !strings.Contains(err.Error(), "does not match the cassette") {
+		t.Errorf("expected the replay of another config not to match the cassette, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package kongtest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+
+	yaml "gopkg.in/mikefarah/yaml.v2"
+)
+
+// Credential collections, by their attribute in declarative configs
+var declarativeCredentials = map[string]string{
+	"acls":                  "acls",
+	"basicauth_credentials": "basic-auths",
+	"hmacauth_credentials":  "hmac-auths",
+	"jwt_secrets":           "jwts",
+	"keyauth_credentials":   "key-auths",
+	"oauth2_credentials":    "oauth2",
+}
+
+// loadConfig serves POST /config, replacing every entity with the ones of a
+// declarative config when running in DB-less mode
+func (s *Server) loadConfig(r *http.Request, body map[string]interface{}) (int, interface{}) {
+	if r.Method != http.MethodPost {
+		return methodNotAllowed()
+	}
+
+	if !s.opts.DBLess {
+		return http.StatusBadRequest, map[string]interface{}{
+			"message": "this endpoint is only available when Kong is configured to not use a database",
+		}
+	}
+
+	raw, ok := body["config"].(string)
+
+	if !ok {
+		return http.StatusBadRequest, map[string]interface{}{"message": "expected a declarative configuration"}
+	}
+
+	decoded := map[interface{}]interface{}{}
+
+	if err := yaml.Unmarshal([]byte(raw), &decoded); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{"message": fmt.Sprintf("failed parsing declarative configuration: %s", err)}
+	}
+
+	config := normalize(decoded).(map[string]interface{})
+	l := &loader{store: newStore(s), fields: map[string]interface{}{}}
+
+	if _, ok := config["_format_version"]; !ok {
+		l.fields["_format_version"] = "required field missing"
+	}
+
+	for i, service := range entityList(config["services"]) {
+		l.service(service, []string{"services", fmt.Sprint(i)})
+	}
+
+	for i, route := range entityList(config["routes"]) {
+		l.route(route, nil, []string{"routes", fmt.Sprint(i)})
+	}
+
+	for i, consumer := range entityList(config["consumers"]) {
+		l.consumer(consumer, []string{"consumers", fmt.Sprint(i)})
+	}
+
+	for i, plugin := range entityList(config["plugins"]) {
+		l.plugin(plugin, nil, []string{"plugins", fmt.Sprint(i)})
+	}
+
+	if len(l.fields) > 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"code":    14,
+			"name":    "invalid declarative configuration",
+			"message": fmt.Sprintf("declarative config is invalid: %s", violationText(l.fields)),
+			"fields":  l.fields,
+		}
+	}
+
+	s.store = l.store
+	sum := md5.Sum([]byte(raw))
+	s.hash = hex.EncodeToString(sum[:])
+
+	loaded := map[string]interface{}{}
+
+	for collection, entities := range s.store.entities {
+		loaded[collection] = entities
+	}
+
+	return http.StatusCreated, loaded
+}
+
+// loader creates the entities of a declarative config in a new store,
+// collecting the errors by the path of the entity in the config
+type loader struct {
+	store  *store
+	fields map[string]interface{}
+}
+
+func (l *loader) service(e map[string]interface{}, path []string) {
+	routes, plugins := entityList(e["routes"]), entityList(e["plugins"])
+	delete(e, "routes")
+	delete(e, "plugins")
+
+	service := l.create("services", e, path)
+
+	if service == nil {
+		return
+	}
+
+	parent := map[string]interface{}{"service": map[string]interface{}{"id": service["id"]}}
+
+	for i, route := range routes {
+		l.route(route, parent, append(path, "routes", fmt.Sprint(i)))
+	}
+
+	for i, plugin := range plugins {
+		l.plugin(plugin, parent, append(path, "plugins", fmt.Sprint(i)))
+	}
+}
+
+func (l *loader) route(e map[string]interface{}, parent map[string]interface{}, path []string) {
+	plugins := entityList(e["plugins"])
+	delete(e, "plugins")
+
+	for k, v := range parent {
+		e[k] = v
+	}
+
+	// Top-level routes reference their service by name
+	if name, ok := e["service"].(string); ok {
+		e["service"] = map[string]interface{}{"name": name}
+	}
+
+	route := l.create("routes", e, path)
+
+	if route == nil {
+		return
+	}
+
+	for i, plugin := range plugins {
+		l.plugin(plugin, map[string]interface{}{"route": map[string]interface{}{"id": route["id"]}}, append(path, "plugins", fmt.Sprint(i)))
+	}
+}
+
+func (l *loader) consumer(e map[string]interface{}, path []string) {
+	attributes := []string{}
+	nested := map[string][]map[string]interface{}{}
+
+	for attribute := range declarativeCredentials {
+		attributes = append(attributes, attribute)
+		nested[attribute] = entityList(e[attribute])
+		delete(e, attribute)
+	}
+
+	sort.Strings(attributes)
+
+	plugins := entityList(e["plugins"])
+	delete(e, "plugins")
+
+	consumer := l.create("consumers", e, path)
+
+	if consumer == nil {
+		return
+	}
+
+	parent := map[string]interface{}{"consumer": map[string]interface{}{"id": consumer["id"]}}
+
+	for _, attribute := range attributes {
+		for i, credential := range nested[attribute] {
+			for k, v := range parent {
+				credential[k] = v
+			}
+
+			l.create(declarativeCredentials[attribute], credential, append(path, attribute, fmt.Sprint(i)))
+		}
+	}
+
+	for i, plugin := range plugins {
+		l.plugin(plugin, parent, append(path, "plugins", fmt.Sprint(i)))
+	}
+}
+
+func (l *loader) plugin(e map[string]interface{}, parent map[string]interface{}, path []string) {
+	for k, v := range parent {
+		e[k] = v
+	}
+
+	// Top-level plugins reference their service, route or consumer by name
+	for _, attribute := range []string{"service", "route", "consumer"} {
+		if name, ok := e[attribute].(string); ok {
+			key := collections[attribute+"s"].endpointKey
+			e[attribute] = map[string]interface{}{key: name}
+		}
+	}
+
+	l.create("plugins", e, path)
+}
+
+// create stores an entity, recording its error at path in the config
+func (l *loader) create(collection string, e map[string]interface{}, path []string) map[string]interface{} {
+	created, err := l.store.create(collection, e)
+
+	if err != nil {
+		violation, ok := err.body["fields"]
+
+		if !ok {
+			violation = err.body["message"]
+		}
+
+		setPath(l.fields, path, violation)
+
+		return nil
+	}
+
+	return created
+}
+
+// setPath sets a value nested in m at path, creating the intermediate objects
+func setPath(m map[string]interface{}, path []string, v interface{}) {
+	for _, k := range path[:len(path)-1] {
+		nested, ok := m[k].(map[string]interface{})
+
+		if !ok {
+			nested = map[string]interface{}{}
+			m[k] = nested
+		}
+
+		m = nested
+	}
+
+	m[path[len(path)-1]] = v
+}
+
+func entityList(v interface{}) []map[string]interface{} {
+	entities := []map[string]interface{}{}
+	list, _ := v.([]interface{})
+
+	for _, item := range list {
+		if e, ok := item.(map[string]interface{}); ok {
+			entities = append(entities, e)
+		}
+	}
+
+	return entities
+}
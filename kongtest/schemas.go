@@ -0,0 +1,236 @@
+package kongtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// Plugins installed by default, the ones kongfig bundles schemas of
+	corePlugins = []string{
+		"acl",
+		"basic-auth",
+		"correlation-id",
+		"cors",
+		"file-log",
+		"hmac-auth",
+		"http-log",
+		"ip-restriction",
+		"jwt",
+		"key-auth",
+		"oauth2",
+		"rate-limiting",
+		"request-size-limiting",
+		"request-transformer",
+	}
+
+	// Credential types, by the name of their entity in Kong schemas
+	credentialEntities = map[string]string{
+		"acls":                  "acls",
+		"basicauth_credentials": "basic-auth",
+		"hmacauth_credentials":  "hmac-auth",
+		"jwt_secrets":           "jwt",
+		"keyauth_credentials":   "key-auth",
+		"oauth2_credentials":    "oauth2",
+	}
+)
+
+// emptySchema is served for installed plugins without a schema, their config
+// accepts no attribute
+const emptySchema = `{"fields": [{"config": {"type": "record", "fields": []}}]}`
+
+// pluginSchema serves /plugins/schema/{name}
+func (s *Server) pluginSchema(r *http.Request, name string) (int, interface{}) {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed()
+	}
+
+	if !s.pluginInstalled(name) {
+		return http.StatusNotFound, map[string]interface{}{"message": fmt.Sprintf("No plugin named '%s'", name)}
+	}
+
+	return http.StatusOK, json.RawMessage(s.schema(name))
+}
+
+// entitySchema serves /schemas/{name}, for the credential entities only
+func (s *Server) entitySchema(r *http.Request, name string) (int, interface{}) {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed()
+	}
+
+	credential, ok := credentialEntities[name]
+
+	if ok && s.installed(credentialTypes[credential]) {
+		if data, _ := s.schemas.Schema("credential", credential); data != nil {
+			return http.StatusOK, json.RawMessage(data)
+		}
+	}
+
+	return http.StatusNotFound, map[string]interface{}{"message": fmt.Sprintf("No entity named '%s'", name)}
+}
+
+// schema returns the schema of an installed plugin, from Options.Schemas first
+func (s *Server) schema(name string) []byte {
+	if schema, ok := s.opts.Schemas[name]; ok {
+		return []byte(schema)
+	}
+
+	if data, _ := s.schemas.Schema("plugin", name); data != nil {
+		return data
+	}
+
+	return []byte(emptySchema)
+}
+
+// pluginConfig rejects plugins that aren't installed or whose config doesn't
+// match their schema, and fills in the default values of the config
+func (s *Server) pluginConfig(e map[string]interface{}) *apiError {
+	name, _ := e["name"].(string)
+
+	if name == "" {
+		return nil
+	}
+
+	if !s.pluginInstalled(name) {
+		return schemaViolationError(map[string]interface{}{
+			"name": fmt.Sprintf("plugin '%s' not enabled; add it to the 'plugins' configuration property", name),
+		})
+	}
+
+	schema := map[string]interface{}{}
+
+	if err := json.Unmarshal(s.schema(name), &schema); err != nil {
+		return nil
+	}
+
+	config, _ := e["config"].(map[string]interface{})
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	for _, f := range fieldList(schema["fields"]) {
+		if f.name != "config" {
+			continue
+		}
+
+		if fields, ok := nestedFields(f.definition); ok {
+			violations := map[string]interface{}{}
+			applyFields(fields, config, violations)
+
+			if len(violations) > 0 {
+				return schemaViolationError(map[string]interface{}{"config": violations})
+			}
+		}
+	}
+
+	// Legacy schemas only describe the config
+	if _, legacy := schema["fields"].(map[string]interface{}); legacy {
+		violations := map[string]interface{}{}
+		applyFields(fieldList(schema["fields"]), config, violations)
+
+		if len(violations) > 0 {
+			return schemaViolationError(map[string]interface{}{"config": violations})
+		}
+	}
+
+	e["config"] = config
+
+	return nil
+}
+
+// field is an attribute of a schema with its definition
+type field struct {
+	name       string
+	definition map[string]interface{}
+}
+
+// fieldList returns the attributes of a schema, either a list of single
+// attribute objects as in Kong 1.x or an object keyed by name as in Kong 0.x
+func fieldList(v interface{}) []field {
+	fields := []field{}
+
+	switch value := v.(type) {
+	case []interface{}:
+		for _, item := range value {
+			m, _ := item.(map[string]interface{})
+
+			for _, name := range sortedKeys(m) {
+				d, _ := m[name].(map[string]interface{})
+				fields = append(fields, field{name: name, definition: d})
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range sortedKeys(value) {
+			d, _ := value[name].(map[string]interface{})
+			fields = append(fields, field{name: name, definition: d})
+		}
+	}
+
+	return fields
+}
+
+// nestedFields returns the attributes of a record attribute
+func nestedFields(d map[string]interface{}) ([]field, bool) {
+	if d["type"] == "record" {
+		return fieldList(d["fields"]), true
+	}
+
+	if nested, ok := d["schema"].(map[string]interface{}); ok {
+		return fieldList(nested["fields"]), true
+	}
+
+	return nil, false
+}
+
+// applyFields sets the default values of missing attributes of value, and
+// reports unknown and missing required attributes in violations
+func applyFields(fields []field, value map[string]interface{}, violations map[string]interface{}) {
+	known := make(map[string]bool)
+
+	for _, f := range fields {
+		known[f.name] = true
+		nested, isRecord := nestedFields(f.definition)
+		v, ok := value[f.name]
+
+		switch {
+		case ok && v != nil && isRecord:
+			record, isMap := v.(map[string]interface{})
+
+			if !isMap {
+				violations[f.name] = "expected a record"
+				continue
+			}
+
+			nestedViolations := map[string]interface{}{}
+			applyFields(nested, record, nestedViolations)
+
+			if len(nestedViolations) > 0 {
+				violations[f.name] = nestedViolations
+			}
+		case ok && v != nil:
+		case f.definition["default"] != nil:
+			value[f.name] = copyValue(normalize(f.definition["default"]))
+		case isRecord && f.definition["type"] == "record":
+			record := map[string]interface{}{}
+			nestedViolations := map[string]interface{}{}
+			applyFields(nested, record, nestedViolations)
+			value[f.name] = record
+
+			if len(nestedViolations) > 0 {
+				violations[f.name] = nestedViolations
+			}
+		case f.definition["required"] == true && f.definition["auto"] != true:
+			violations[f.name] = "required field missing"
+		default:
+			value[f.name] = nil
+		}
+	}
+
+	for _, name := range sortedKeys(value) {
+		if !known[name] {
+			violations[name] = "unknown field"
+		}
+	}
+}
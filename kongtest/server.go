@@ -0,0 +1,602 @@
+// Package kongtest provides an in-memory Kong Admin API served by httptest,
+// to test programs applying or diffing configs without a running Kong.
+//
+//	server := kongtest.NewServer(kongtest.Options{})
+//	defer server.Close()
+//
+//	client := server.Client(config)
+//	err := client.ApplyConfig()
+//	services := server.Entities("services")
+package kongtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pagerinc/kongfig/api"
+)
+
+// DefaultVersion is the Kong version reported when Options.Version is empty
+const DefaultVersion = "1.1.0"
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Options configures the behavior of a Server
+type Options struct {
+	// Version reported by GET /, DefaultVersion when empty
+	Version string
+	// DBLess makes the entity endpoints read-only, configs are then loaded through POST /config
+	DBLess bool
+	// PageSize of paginated collections when the size query parameter is omitted
+	PageSize int
+	// Plugins installed in Kong, the core plugins kongfig bundles schemas of when empty
+	Plugins []string
+	// Schemas of plugins kongfig doesn't bundle, as served by /plugins/schema/{name}
+	Schemas map[string]string
+	// Enterprise serves the workspaces of Kong Enterprise under /{workspace}/,
+	// along with /workspaces to list and create them
+	Enterprise bool
+	// Workspaces existing besides the default one when the server starts, with Enterprise
+	Workspaces []string
+}
+
+// Server is an in-memory Kong Admin API. Entities are kept in memory for the
+// lifetime of the server and can be inspected with Entities
+type Server struct {
+	*httptest.Server
+
+	opts    Options
+	schemas api.SchemaSource
+	dir     string
+	mu      sync.Mutex
+	store   *store
+	// Workspaces by name, the default one sharing store
+	workspaces map[string]*workspace
+	requests   []string
+	failures   []failure
+	hash       string
+}
+
+// failure is an error injected with Fail
+type failure struct {
+	method string
+	path   string
+	status int
+}
+
+// NewServer starts an in-memory Admin API, it must be closed by the caller
+func NewServer(opts Options) *Server {
+	if opts.Version == "" {
+		opts.Version = DefaultVersion
+	}
+
+	if opts.PageSize == 0 {
+		opts.PageSize = defaultPageSize
+	}
+
+	schemas := api.BundledSchemas(opts.Version)
+
+	if schemas == nil {
+		schemas = api.BundledSchemas(DefaultVersion)
+	}
+
+	if len(opts.Plugins) == 0 {
+		opts.Plugins = corePlugins
+	}
+
+	s := &Server{opts: opts, schemas: schemas}
+	s.store = newStore(s)
+	s.resetWorkspaces()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Close shuts the server down and removes the files written by its clients
+func (s *Server) Close() {
+	s.Server.Close()
+
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// Host returns the address of the server, as set in the host attribute of configs
+func (s *Server) Host() string {
+	return s.Listener.Addr().String()
+}
+
+// Client returns a kongfig client applying config to the server. Events and
+// logs are discarded, and snapshots, journals and schemas are written to a temporary
+// directory removed by Close
+func (s *Server) Client(config *api.Config) *api.Client {
+	config.Host = s.Host()
+	config.HTTPS = false
+
+	c := api.NewConfigClient(config)
+	c.Reporter, _ = api.NewReporter(api.OutputText, ioutil.Discard)
+	c.Logger = api.NopLogger()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		s.dir, _ = ioutil.TempDir("", "kongtest")
+	}
+
+	c.SnapshotDir = filepath.Join(s.dir, "snapshots")
+	c.JournalDir = filepath.Join(s.dir, "journals")
+	c.SchemaDir = filepath.Join(s.dir, "schemas")
+
+	return c
+}
+
+// Entities returns a copy of the entities of a collection as returned by the
+// Admin API, eg: services, routes, plugins, consumers or key-auths
+func (s *Server) Entities(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities := []map[string]interface{}{}
+
+	for _, e := range s.store.entities[collection] {
+		entities = append(entities, copyEntity(e))
+	}
+
+	return entities
+}
+
+// Seed creates an entity in a collection as POST would, also when the server
+// runs in DB-less mode. Foreign keys reference entities by ID or name, eg:
+// {"service": {"name": "api"}}
+func (s *Server) Seed(collection string, attributes map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := collections[collection]; !ok {
+		return nil, fmt.Errorf("Unknown collection %s", collection)
+	}
+
+	e, err := s.store.create(collection, normalize(attributes).(map[string]interface{}))
+
+	if err != nil {
+		return nil, fmt.Errorf("[HTTP %d] %s", err.status, err.body["message"])
+	}
+
+	return copyEntity(e), nil
+}
+
+// Requests returns every request served so far, as "METHOD /path?query"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// Fail makes the next request matching method and path fail with status and
+// the error body Kong returns on unexpected errors, eg: to test rollbacks
+func (s *Server) Fail(method, path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, failure{method: method, path: path, status: status})
+}
+
+// Reset deletes every entity and forgets the requests served so far
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store = newStore(s)
+	s.resetWorkspaces()
+	s.requests = nil
+	s.failures = nil
+	s.hash = ""
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request := r.Method + " " + r.URL.Path
+
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+
+	s.requests = append(s.requests, request)
+
+	for i, f := range s.failures {
+		if f.method == r.Method && f.path == r.URL.Path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			writeJSON(w, f.status, map[string]interface{}{"message": "An unexpected error occurred"})
+
+			return
+		}
+	}
+
+	body := map[string]interface{}{}
+
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		data, _ := ioutil.ReadAll(r.Body)
+
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := json.Unmarshal(data, &body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Cannot parse JSON body"})
+				return
+			}
+		}
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// Requests to /{workspace}/ are served with the entities of the workspace
+	if ws, ok := s.workspaces[segments[0]]; ok && len(segments) > 1 {
+		root := s.store
+		s.store = ws.store
+		segments = segments[1:]
+
+		defer func() { s.store = root }()
+	}
+
+	status, response := s.route(r, segments, body)
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	writeJSON(w, status, response)
+}
+
+// route dispatches a request to the handler of its endpoint
+func (s *Server) route(r *http.Request, segments []string, body map[string]interface{}) (int, interface{}) {
+	switch {
+	case len(segments) == 1 && segments[0] == "":
+		return s.nodeInfo(r)
+	case segments[0] == "status" && len(segments) == 1:
+		return s.status(r)
+	case segments[0] == "config" && len(segments) == 1:
+		return s.loadConfig(r, body)
+	case segments[0] == "plugins" && len(segments) == 2 && segments[1] == "enabled":
+		return s.enabledPlugins(r)
+	case segments[0] == "plugins" && len(segments) == 3 && segments[1] == "schema":
+		return s.pluginSchema(r, segments[2])
+	case segments[0] == "schemas" && len(segments) == 2:
+		return s.entitySchema(r, segments[1])
+	case segments[0] == "workspaces" && s.opts.Enterprise:
+		return s.workspaceEndpoint(r, segments[1:], body)
+	}
+
+	collection := segments[0]
+
+	if _, ok := collections[collection]; !ok || !s.installed(collection) {
+		return notFound()
+	}
+
+	switch len(segments) {
+	case 1:
+		return s.collection(r, collection, nil, body)
+	case 2:
+		return s.entity(r, collection, nil, segments[1], body)
+	}
+
+	parentAttribute, ok := parentAttributes[collection]
+
+	if !ok {
+		return notFound()
+	}
+
+	child, ok := nestedCollection(collection, segments[2])
+
+	if !ok || !s.installed(child) {
+		return notFound()
+	}
+
+	parent := s.store.find(collection, segments[1])
+
+	if parent == nil {
+		return notFound()
+	}
+
+	scope := map[string]string{parentAttribute: parent["id"].(string)}
+
+	switch len(segments) {
+	case 3:
+		return s.collection(r, child, scope, body)
+	case 4:
+		return s.entity(r, child, scope, segments[3], body)
+	}
+
+	return notFound()
+}
+
+// collection serves the list and create endpoints of a collection, scope
+// restricts it to the entities referencing a parent entity
+func (s *Server) collection(r *http.Request, collection string, scope map[string]string, body map[string]interface{}) (int, interface{}) {
+	switch r.Method {
+	case http.MethodGet:
+		return s.list(r, collection, scope)
+	case http.MethodPost:
+		if s.opts.DBLess {
+			return readOnly("create", collection)
+		}
+
+		e, err := s.store.create(collection, scoped(body, scope))
+
+		if err != nil {
+			return err.status, err.body
+		}
+
+		return http.StatusCreated, e
+	}
+
+	return methodNotAllowed()
+}
+
+// entity serves the endpoints of a single entity, referenced by ID or endpoint key
+func (s *Server) entity(r *http.Request, collection string, scope map[string]string, ref string, body map[string]interface{}) (int, interface{}) {
+	e := s.store.find(collection, ref)
+
+	if e != nil && !inScope(e, scope) {
+		e = nil
+	}
+
+	if s.opts.DBLess && r.Method != http.MethodGet {
+		verbs := map[string]string{http.MethodPut: "create or update", http.MethodPatch: "update", http.MethodDelete: "delete"}
+		return readOnly(verbs[r.Method], collection)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if e == nil {
+			return notFound()
+		}
+
+		return http.StatusOK, e
+	case http.MethodPatch:
+		if e == nil {
+			return notFound()
+		}
+
+		updated, err := s.store.update(collection, e, scoped(body, scope))
+
+		if err != nil {
+			return err.status, err.body
+		}
+
+		return http.StatusOK, updated
+	case http.MethodPut:
+		upserted, err := s.store.upsert(collection, e, ref, scoped(body, scope))
+
+		if err != nil {
+			return err.status, err.body
+		}
+
+		return http.StatusOK, upserted
+	case http.MethodDelete:
+		if e == nil {
+			return http.StatusNoContent, nil
+		}
+
+		if err := s.store.delete(collection, e); err != nil {
+			return err.status, err.body
+		}
+
+		return http.StatusNoContent, nil
+	}
+
+	return methodNotAllowed()
+}
+
+// list serves a page of a collection, filtered by scope and tags
+func (s *Server) list(r *http.Request, collection string, scope map[string]string) (int, interface{}) {
+	query := r.URL.Query()
+	size := s.opts.PageSize
+
+	if v := query.Get("size"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &size); err != nil || size < 1 || size > maxPageSize {
+			return schemaViolation(map[string]interface{}{"size": fmt.Sprintf("must be an integer between 1 and %d", maxPageSize)})
+		}
+	}
+
+	offset := 0
+
+	if v := query.Get("offset"); v != "" {
+		var ok bool
+
+		if offset, ok = decodeOffset(v); !ok {
+			return http.StatusBadRequest, map[string]interface{}{
+				"code":    7,
+				"name":    "invalid offset",
+				"message": fmt.Sprintf("'%s' is not a valid offset: bad base64 encoding", v),
+			}
+		}
+	}
+
+	matches := []map[string]interface{}{}
+
+	for _, e := range s.store.entities[collection] {
+		if inScope(e, scope) && tagged(e, query.Get("tags")) {
+			matches = append(matches, e)
+		}
+	}
+
+	page := map[string]interface{}{"data": []map[string]interface{}{}, "next": nil}
+
+	if offset >= len(matches) {
+		return http.StatusOK, page
+	}
+
+	end := offset + size
+
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	page["data"] = matches[offset:end]
+
+	if end < len(matches) {
+		next := encodeOffset(end)
+		query.Set("offset", next)
+		page["next"] = r.URL.Path + "?" + query.Encode()
+		page["offset"] = next
+	}
+
+	return http.StatusOK, page
+}
+
+func (s *Server) nodeInfo(r *http.Request) (int, interface{}) {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed()
+	}
+
+	database := "postgres"
+
+	if s.opts.DBLess {
+		database = "off"
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"version":  s.opts.Version,
+		"tagline":  "Welcome to kong",
+		"hostname": "kongtest",
+		"configuration": map[string]interface{}{
+			"database": database,
+		},
+		"plugins": map[string]interface{}{
+			"enabled_in_cluster": s.enabledInCluster(),
+		},
+	}
+}
+
+func (s *Server) status(r *http.Request) (int, interface{}) {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed()
+	}
+
+	status := map[string]interface{}{
+		"database": map[string]interface{}{"reachable": true},
+		"server":   map[string]interface{}{"total_requests": len(s.requests)},
+	}
+
+	if s.opts.DBLess {
+		status["configuration_hash"] = s.hash
+	}
+
+	return http.StatusOK, status
+}
+
+// enabledInCluster lists the plugins in use, like the node information of Kong
+func (s *Server) enabledInCluster() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+
+	for _, p := range s.store.entities["plugins"] {
+		name, _ := p["name"].(string)
+
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (s *Server) enabledPlugins(r *http.Request) (int, interface{}) {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed()
+	}
+
+	return http.StatusOK, map[string]interface{}{"enabled_plugins": s.opts.Plugins}
+}
+
+// installed reports whether the plugin providing a credential collection is
+// installed, other collections are always available
+func (s *Server) installed(collection string) bool {
+	plugin, ok := credentialPlugins[collection]
+
+	return !ok || s.pluginInstalled(plugin)
+}
+
+func (s *Server) pluginInstalled(name string) bool {
+	for _, p := range s.opts.Plugins {
+		if p == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scoped sets the foreign keys of scope on the body of a nested endpoint,
+// overriding the ones of the body like Kong does
+func scoped(body map[string]interface{}, scope map[string]string) map[string]interface{} {
+	for attribute, id := range scope {
+		body[attribute] = map[string]interface{}{"id": id}
+	}
+
+	return body
+}
+
+func inScope(e map[string]interface{}, scope map[string]string) bool {
+	for attribute, id := range scope {
+		if foreignID(e, attribute) != id {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tagged filters entities by the tags query parameter: tags separated by
+// commas must all be set, tags separated by slashes any of them
+func tagged(e map[string]interface{}, filter string) bool {
+	if filter == "" {
+		return true
+	}
+
+	tags := make(map[string]bool)
+	list, _ := e["tags"].([]interface{})
+
+	for _, t := range list {
+		tags[fmt.Sprint(t)] = true
+	}
+
+	if strings.Contains(filter, "/") {
+		for _, t := range strings.Split(filter, "/") {
+			if tags[t] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, t := range strings.Split(filter, ",") {
+		if !tags[t] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Server", "kong/kongtest")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,118 @@
+package kongtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// request makes a request to the server, returning the status and decoded body of the response
+func request(t *testing.T, s *Server, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	decoded := map[string]interface{}{}
+	json.NewDecoder(res.Body).Decode(&decoded)
+
+	return res.StatusCode, decoded
+}
+
+func TestServerPagination(t *testing.T) {
+	s := NewServer(Options{PageSize: 2})
+	defer s.Close()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := s.Seed("services", map[string]interface{}{"name": name, "host": name + ".internal"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, page := request(t, s, http.MethodGet, "/services", "")
+
+	if status != http.StatusOK || len(page["data"].([]interface{})) != 2 {
+		t.Fatalf("expected a first page of 2 services, got %d %v", status, page)
+	}
+
+	next, _ := page["next"].(string)
+	_, page = request(t, s, http.MethodGet, next, "")
+
+	if len(page["data"].([]interface{})) != 1 || page["next"] != nil {
+		t.Errorf("expected a last page of 1 service, got %v", page)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	if status, _ := request(t, s, http.MethodPost, "/services", `{"name": "api", "url": "http://api.internal"}`); status != http.StatusCreated {
+		t.Fatalf("expected the service to be created, got %d", status)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		name               string
+	}{
+		{http.MethodPost, "/services", `{"name": "api", "host": "other.internal"}`, http.StatusConflict, "unique constraint violation"},
+		{http.MethodPost, "/services", `{"name": "other", "unknown": 1}`, http.StatusBadRequest, "schema violation"},
+		{http.MethodPost, "/routes", `{"paths": ["/"], "service": {"name": "missing"}}`, http.StatusBadRequest, "foreign key violation"},
+		{http.MethodPost, "/plugins", `{"name": "not-installed"}`, http.StatusBadRequest, "schema violation"},
+	} {
+		status, body := request(t, s, tc.method, tc.path, tc.body)
+
+		if status != tc.status || body["name"] != tc.name {
+			t.Errorf("%s %s: expected %d %s, got %d %v", tc.method, tc.path, tc.status, tc.name, status, body)
+		}
+	}
+}
+
+func TestServerFail(t *testing.T) {
+	s := NewServer(Options{})
+	defer s.Close()
+
+	s.Fail(http.MethodPost, "/services", http.StatusServiceUnavailable)
+
+	if status, _ := request(t, s, http.MethodPost, "/services", `{"name": "api", "host": "api.internal"}`); status != http.StatusServiceUnavailable {
+		t.Errorf("expected the injected failure, got %d", status)
+	}
+
+	if n := len(s.Entities("services")); n != 0 {
+		t.Errorf("expected the failed request to change nothing, got %d services", n)
+	}
+
+	// Failures only apply to the next matching request
+	if status, _ := request(t, s, http.MethodPost, "/services", `{"name": "api", "host": "api.internal"}`); status != http.StatusCreated {
+		t.Errorf("expected the service to be created, got %d", status)
+	}
+}
+
+func TestServerDBLess(t *testing.T) {
+	s := NewServer(Options{DBLess: true})
+	defer s.Close()
+
+	if status, _ := request(t, s, http.MethodPost, "/services", `{"name": "api", "host": "api.internal"}`); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected entity endpoints to be read-only, got %d", status)
+	}
+
+	status, _ := request(t, s, http.MethodPost, "/config", `{"config": "_format_version: '1.1'\nservices:\n- name: api\n  host: api.internal\n"}`)
+
+	if status != http.StatusCreated || names(s, "services") != "api" {
+		t.Errorf("expected the declarative config to be loaded, got %d and services %s", status, names(s, "services"))
+	}
+}
@@ -0,0 +1,813 @@
+package kongtest
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collection describes the attributes and constraints of a Kong collection
+type collection struct {
+	// Attribute identifying entities in URLs besides their ID
+	endpointKey string
+	// Attributes whose values must be unique across the collection
+	unique []string
+	// Foreign keys, by attribute, to the collection they reference
+	foreign map[string]string
+	// Attributes Kong stores, absent ones are returned as null
+	fields   []string
+	required []string
+	defaults map[string]interface{}
+	// Attributes Kong generates when omitted
+	generated []string
+}
+
+var (
+	credentialFields = []string{"id", "created_at", "consumer", "tags"}
+
+	collections = map[string]collection{
+		"services": {
+			endpointKey: "name",
+			unique:      []string{"name"},
+			fields:      []string{"id", "created_at", "updated_at", "name", "protocol", "host", "port", "path", "retries", "connect_timeout", "write_timeout", "read_timeout", "enabled", "tags"},
+			required:    []string{"host"},
+			defaults: map[string]interface{}{
+				"protocol":        "http",
+				"port":            80,
+				"retries":         5,
+				"connect_timeout": 60000,
+				"write_timeout":   60000,
+				"read_timeout":    60000,
+			},
+		},
+		"routes": {
+			endpointKey: "name",
+			unique:      []string{"name"},
+			foreign:     map[string]string{"service": "services"},
+			fields:      []string{"id", "created_at", "updated_at", "name", "service", "protocols", "methods", "hosts", "paths", "headers", "regex_priority", "strip_path", "preserve_host", "https_redirect_status_code", "snis", "sources", "destinations", "tags"},
+			defaults: map[string]interface{}{
+				"protocols":                  []interface{}{"http", "https"},
+				"regex_priority":             0,
+				"strip_path":                 true,
+				"preserve_host":              false,
+				"https_redirect_status_code": 426,
+			},
+		},
+		"plugins": {
+			foreign:  map[string]string{"service": "services", "route": "routes", "consumer": "consumers"},
+			fields:   []string{"id", "created_at", "name", "service", "route", "consumer", "config", "run_on", "protocols", "enabled", "tags"},
+			required: []string{"name"},
+			defaults: map[string]interface{}{
+				"run_on":    "first",
+				"protocols": []interface{}{"http", "https"},
+				"enabled":   true,
+			},
+		},
+		"consumers": {
+			endpointKey: "username",
+			unique:      []string{"username", "custom_id"},
+			fields:      []string{"id", "created_at", "username", "custom_id", "tags"},
+		},
+		"acls": {
+			foreign:  map[string]string{"consumer": "consumers"},
+			fields:   append([]string{"group"}, credentialFields...),
+			required: []string{"consumer", "group"},
+		},
+		"basic-auths": {
+			endpointKey: "username",
+			unique:      []string{"username"},
+			foreign:     map[string]string{"consumer": "consumers"},
+			fields:      append([]string{"username", "password"}, credentialFields...),
+			required:    []string{"consumer", "username"},
+		},
+		"hmac-auths": {
+			endpointKey: "username",
+			unique:      []string{"username"},
+			foreign:     map[string]string{"consumer": "consumers"},
+			fields:      append([]string{"username", "secret"}, credentialFields...),
+			required:    []string{"consumer", "username"},
+			generated:   []string{"secret"},
+		},
+		"jwts": {
+			endpointKey: "key",
+			unique:      []string{"key"},
+			foreign:     map[string]string{"consumer": "consumers"},
+			fields:      append([]string{"key", "secret", "rsa_public_key", "algorithm"}, credentialFields...),
+			required:    []string{"consumer"},
+			defaults:    map[string]interface{}{"algorithm": "HS256"},
+			generated:   []string{"key", "secret"},
+		},
+		"key-auths": {
+			endpointKey: "key",
+			unique:      []string{"key"},
+			foreign:     map[string]string{"consumer": "consumers"},
+			fields:      append([]string{"key", "ttl"}, credentialFields...),
+			required:    []string{"consumer"},
+			generated:   []string{"key"},
+		},
+		"oauth2": {
+			endpointKey: "client_id",
+			unique:      []string{"client_id"},
+			foreign:     map[string]string{"consumer": "consumers"},
+			fields:      append([]string{"name", "client_id", "client_secret", "redirect_uris"}, credentialFields...),
+			required:    []string{"consumer", "name"},
+			generated:   []string{"client_id", "client_secret"},
+		},
+	}
+
+	// Credential collections, by the name of their endpoint under consumers
+	credentialTypes = map[string]string{
+		"acls":       "acls",
+		"basic-auth": "basic-auths",
+		"hmac-auth":  "hmac-auths",
+		"jwt":        "jwts",
+		"key-auth":   "key-auths",
+		"oauth2":     "oauth2",
+	}
+
+	// Plugins providing the credential collections
+	credentialPlugins = map[string]string{
+		"acls":        "acl",
+		"basic-auths": "basic-auth",
+		"hmac-auths":  "hmac-auth",
+		"jwts":        "jwt",
+		"key-auths":   "key-auth",
+		"oauth2":      "oauth2",
+	}
+
+	// Foreign key attributes referencing the entities of a collection
+	parentAttributes = map[string]string{
+		"services":  "service",
+		"routes":    "route",
+		"consumers": "consumer",
+	}
+
+	uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// nestedCollection returns the collection served under the entities of parent at segment
+func nestedCollection(parent, segment string) (string, bool) {
+	switch {
+	case parent == "services" && (segment == "routes" || segment == "plugins"):
+		return segment, true
+	case parent == "routes" && segment == "plugins":
+		return segment, true
+	case parent == "consumers" && segment == "plugins":
+		return segment, true
+	case parent == "consumers":
+		collection, ok := credentialTypes[segment]
+		return collection, ok
+	}
+
+	return "", false
+}
+
+// store holds the entities of every collection in creation order
+type store struct {
+	server   *Server
+	entities map[string][]map[string]interface{}
+}
+
+func newStore(s *Server) *store {
+	return &store{server: s, entities: make(map[string][]map[string]interface{})}
+}
+
+// find returns the entity of a collection with the given ID or endpoint key
+func (st *store) find(collection, ref string) map[string]interface{} {
+	key := collections[collection].endpointKey
+
+	for _, e := range st.entities[collection] {
+		if e["id"] == ref || (key != "" && e[key] == ref) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// create validates the attributes of a new entity and stores it
+func (st *store) create(collection string, body map[string]interface{}) (map[string]interface{}, *apiError) {
+	e, err := st.build(collection, nil, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if e["id"] == nil {
+		e["id"] = newUUID()
+	}
+
+	if err := st.check(collection, e, nil); err != nil {
+		return nil, err
+	}
+
+	st.entities[collection] = append(st.entities[collection], e)
+
+	return e, nil
+}
+
+// update applies the attributes of body over an existing entity
+func (st *store) update(collection string, existing, body map[string]interface{}) (map[string]interface{}, *apiError) {
+	e, err := st.build(collection, existing, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e["id"] = existing["id"]
+
+	if err := st.check(collection, e, existing); err != nil {
+		return nil, err
+	}
+
+	st.replace(collection, existing, e)
+
+	return e, nil
+}
+
+// upsert replaces the entity referenced by ref, or creates it with ref as
+// its ID or endpoint key
+func (st *store) upsert(collection string, existing map[string]interface{}, ref string, body map[string]interface{}) (map[string]interface{}, *apiError) {
+	if existing == nil {
+		if uuidPattern.MatchString(ref) {
+			body["id"] = ref
+		} else if key := collections[collection].endpointKey; key != "" {
+			body[key] = ref
+		} else {
+			return notFoundError()
+		}
+
+		return st.create(collection, body)
+	}
+
+	e, err := st.build(collection, nil, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e["id"] = existing["id"]
+	e["created_at"] = existing["created_at"]
+
+	if key := collections[collection].endpointKey; key != "" && existing[key] == ref && e[key] == nil {
+		e[key] = ref
+	}
+
+	if err := st.check(collection, e, existing); err != nil {
+		return nil, err
+	}
+
+	st.replace(collection, existing, e)
+
+	return e, nil
+}
+
+// delete removes an entity along with the plugins and credentials referencing it
+func (st *store) delete(collection string, e map[string]interface{}) *apiError {
+	attribute := parentAttributes[collection]
+
+	if collection == "services" {
+		for _, r := range st.entities["routes"] {
+			if foreignID(r, "service") == e["id"] {
+				return &apiError{status: http.StatusBadRequest, body: map[string]interface{}{
+					"code":    4,
+					"name":    "foreign key violation",
+					"message": "an existing 'routes' entity references this 'services' entity",
+					"fields":  map[string]interface{}{"@referenced_by": "routes"},
+				}}
+			}
+		}
+	}
+
+	st.remove(collection, e)
+
+	if attribute == "" {
+		return nil
+	}
+
+	for name, c := range collections {
+		if c.foreign[attribute] != collection {
+			continue
+		}
+
+		for _, child := range append([]map[string]interface{}{}, st.entities[name]...) {
+			if foreignID(child, attribute) == e["id"] {
+				st.delete(name, child)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (st *store) replace(collection string, existing, e map[string]interface{}) {
+	for i, candidate := range st.entities[collection] {
+		if candidate["id"] == existing["id"] {
+			st.entities[collection][i] = e
+		}
+	}
+}
+
+func (st *store) remove(collection string, e map[string]interface{}) {
+	kept := []map[string]interface{}{}
+
+	for _, candidate := range st.entities[collection] {
+		if candidate["id"] != e["id"] {
+			kept = append(kept, candidate)
+		}
+	}
+
+	st.entities[collection] = kept
+}
+
+// build returns the entity resulting from body applied over base, or over
+// the defaults of the collection for new entities. It rejects unknown
+// attributes and resolves foreign keys
+func (st *store) build(collection string, base, body map[string]interface{}) (map[string]interface{}, *apiError) {
+	c := collections[collection]
+	violations := map[string]interface{}{}
+	known := make(map[string]bool)
+
+	for _, f := range c.fields {
+		known[f] = true
+	}
+
+	if collection == "services" {
+		if err := expandURL(body); err != nil {
+			violations["url"] = err.Error()
+		}
+	}
+
+	for _, attribute := range sortedKeys(body) {
+		if !known[attribute] {
+			violations[attribute] = "unknown field"
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, schemaViolationError(violations)
+	}
+
+	e := map[string]interface{}{}
+
+	if base == nil {
+		for _, f := range c.fields {
+			e[f] = copyValue(c.defaults[f])
+		}
+
+		e["created_at"] = float64(time.Now().Unix())
+	} else {
+		e = copyEntity(base)
+	}
+
+	for attribute, v := range body {
+		if attribute == "config" && base != nil {
+			baseConfig, _ := e["config"].(map[string]interface{})
+			config, _ := v.(map[string]interface{})
+			e["config"] = merge(baseConfig, config)
+
+			continue
+		}
+
+		e[attribute] = copyValue(v)
+	}
+
+	for attribute, target := range c.foreign {
+		if e[attribute] == nil {
+			continue
+		}
+
+		if err := st.resolveForeign(e, attribute, target); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attribute := range c.generated {
+		if e[attribute] == nil {
+			e[attribute] = randomHex(16)
+		}
+	}
+
+	if collection == "basic-auths" {
+		if password, ok := body["password"].(string); ok {
+			sum := sha1.Sum([]byte(password + foreignID(e, "consumer")))
+			e["password"] = hex.EncodeToString(sum[:])
+		}
+	}
+
+	if collection == "plugins" {
+		if err := st.server.pluginConfig(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return normalize(e).(map[string]interface{}), nil
+}
+
+// resolveForeign replaces a foreign key by the ID of the entity it references,
+// which may be given by ID or endpoint key
+func (st *store) resolveForeign(e map[string]interface{}, attribute, target string) *apiError {
+	ref, ok := e[attribute].(map[string]interface{})
+
+	if !ok {
+		return schemaViolationError(map[string]interface{}{attribute: "expected a record"})
+	}
+
+	var referenced map[string]interface{}
+
+	for _, k := range []string{"id", collections[target].endpointKey} {
+		if v, ok := ref[k].(string); ok && k != "" && referenced == nil {
+			referenced = st.find(target, v)
+		}
+	}
+
+	if referenced == nil {
+		return &apiError{status: http.StatusBadRequest, body: map[string]interface{}{
+			"code":    4,
+			"name":    "foreign key violation",
+			"message": fmt.Sprintf("the foreign key '%s' does not reference an existing '%s' entity.", luaTable(ref), target),
+			"fields":  map[string]interface{}{attribute: ref},
+		}}
+	}
+
+	e[attribute] = map[string]interface{}{"id": referenced["id"]}
+
+	return nil
+}
+
+// check validates the required attributes and unique constraints of an
+// entity, ignoring the existing entity it replaces
+func (st *store) check(collection string, e, existing map[string]interface{}) *apiError {
+	c := collections[collection]
+	violations := map[string]interface{}{}
+
+	for _, attribute := range c.required {
+		if isEmpty(e[attribute]) {
+			violations[attribute] = "required field missing"
+		}
+	}
+
+	switch collection {
+	case "routes":
+		if !isEmpty(e["protocols"]) && isEmpty(e["methods"]) && isEmpty(e["hosts"]) && isEmpty(e["paths"]) && isEmpty(e["headers"]) {
+			violations["@entity"] = []interface{}{"must set one of 'methods', 'hosts', 'headers', 'paths' when 'protocols' is 'http' or 'https'"}
+		}
+	case "consumers":
+		if isEmpty(e["username"]) && isEmpty(e["custom_id"]) {
+			violations["@entity"] = []interface{}{"at least one of these fields must be non-empty: 'custom_id', 'username'"}
+		}
+	}
+
+	if len(violations) > 0 {
+		return schemaViolationError(violations)
+	}
+
+	for _, other := range st.entities[collection] {
+		if existing != nil && other["id"] == existing["id"] {
+			continue
+		}
+
+		if other["id"] == e["id"] {
+			return uniqueViolationError(map[string]interface{}{"id": e["id"]})
+		}
+
+		for _, attribute := range c.unique {
+			if e[attribute] != nil && other[attribute] == e[attribute] {
+				return uniqueViolationError(map[string]interface{}{attribute: e[attribute]})
+			}
+		}
+
+		if collection == "plugins" && samePluginScope(e, other) {
+			return uniqueViolationError(map[string]interface{}{
+				"name":     e["name"],
+				"service":  e["service"],
+				"route":    e["route"],
+				"consumer": e["consumer"],
+			})
+		}
+	}
+
+	return nil
+}
+
+func samePluginScope(a, b map[string]interface{}) bool {
+	if a["name"] != b["name"] {
+		return false
+	}
+
+	for _, attribute := range []string{"service", "route", "consumer"} {
+		if foreignID(a, attribute) != foreignID(b, attribute) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expandURL replaces the url shorthand of a service by the attributes it sets
+func expandURL(body map[string]interface{}) error {
+	raw, ok := body["url"].(string)
+
+	if !ok {
+		return nil
+	}
+
+	delete(body, "url")
+
+	u, err := url.Parse(raw)
+
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		return fmt.Errorf("missing host in url")
+	}
+
+	body["protocol"] = u.Scheme
+	body["host"] = u.Hostname()
+	body["port"] = 80
+	body["path"] = nil
+
+	if u.Scheme == "https" {
+		body["port"] = 443
+	}
+
+	if u.Port() != "" {
+		body["port"], _ = strconv.Atoi(u.Port())
+	}
+
+	if u.Path != "" {
+		body["path"] = u.Path
+	}
+
+	return nil
+}
+
+// apiError is an error response of the Admin API
+type apiError struct {
+	status int
+	body   map[string]interface{}
+}
+
+func notFound() (int, interface{}) {
+	return http.StatusNotFound, map[string]interface{}{"message": "Not found"}
+}
+
+func notFoundError() (map[string]interface{}, *apiError) {
+	status, body := notFound()
+
+	return nil, &apiError{status: status, body: body.(map[string]interface{})}
+}
+
+func methodNotAllowed() (int, interface{}) {
+	return http.StatusMethodNotAllowed, map[string]interface{}{"message": "Method not allowed"}
+}
+
+func readOnly(verb, collection string) (int, interface{}) {
+	return http.StatusMethodNotAllowed, map[string]interface{}{
+		"message": fmt.Sprintf("cannot %s '%s' entities when not using a database", verb, collection),
+	}
+}
+
+func schemaViolation(fields map[string]interface{}) (int, interface{}) {
+	err := schemaViolationError(fields)
+
+	return err.status, err.body
+}
+
+// schemaViolationError returns the error of invalid attributes, summarizing them in the message
+func schemaViolationError(fields map[string]interface{}) *apiError {
+	summary := []string{}
+
+	for _, attribute := range sortedKeys(fields) {
+		summary = append(summary, fmt.Sprintf("%s: %s", attribute, violationText(fields[attribute])))
+	}
+
+	message := fmt.Sprintf("schema violation (%s)", strings.Join(summary, "; "))
+
+	if len(summary) > 1 {
+		message = fmt.Sprintf("%d schema violations (%s)", len(summary), strings.Join(summary, "; "))
+	}
+
+	return &apiError{status: http.StatusBadRequest, body: map[string]interface{}{
+		"code":    2,
+		"name":    "schema violation",
+		"message": message,
+		"fields":  fields,
+	}}
+}
+
+func violationText(v interface{}) string {
+	switch value := v.(type) {
+	case []interface{}:
+		texts := []string{}
+
+		for _, item := range value {
+			texts = append(texts, violationText(item))
+		}
+
+		return strings.Join(texts, ", ")
+	case map[string]interface{}:
+		texts := []string{}
+
+		for _, k := range sortedKeys(value) {
+			texts = append(texts, fmt.Sprintf("%s: %s", k, violationText(value[k])))
+		}
+
+		return "{" + strings.Join(texts, ", ") + "}"
+	}
+
+	return fmt.Sprint(v)
+}
+
+func uniqueViolationError(fields map[string]interface{}) *apiError {
+	return &apiError{status: http.StatusConflict, body: map[string]interface{}{
+		"code":    5,
+		"name":    "unique constraint violation",
+		"message": fmt.Sprintf("UNIQUE violation detected on '%s'", luaTable(fields)),
+		"fields":  fields,
+	}}
+}
+
+// luaTable formats attributes the way Kong prints them in error messages
+func luaTable(fields map[string]interface{}) string {
+	parts := []string{}
+
+	for _, k := range sortedKeys(fields) {
+		switch v := fields[k].(type) {
+		case nil:
+			parts = append(parts, k+"=null")
+		case string:
+			parts = append(parts, fmt.Sprintf("%s=%q", k, v))
+		case map[string]interface{}:
+			parts = append(parts, k+"="+luaTable(v))
+		default:
+			parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func foreignID(e map[string]interface{}, attribute string) string {
+	ref, _ := e[attribute].(map[string]interface{})
+	id, _ := ref["id"].(string)
+
+	return id
+}
+
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+
+	return false
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// encodeOffset returns the opaque offset of a page starting at index
+func encodeOffset(index int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(index)))
+}
+
+func decodeOffset(offset string) (int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(offset)
+
+	if err != nil {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(string(data))
+
+	return index, err == nil && index >= 0
+}
+
+// merge deep merges override over base, like PATCH does on plugin configs
+func merge(base, override map[string]interface{}) map[string]interface{} {
+	merged := copyEntity(base)
+
+	for k, v := range override {
+		baseMap, baseIsMap := merged[k].(map[string]interface{})
+		overrideMap, overrideIsMap := v.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			merged[k] = merge(baseMap, overrideMap)
+		} else {
+			merged[k] = copyValue(v)
+		}
+	}
+
+	return merged
+}
+
+func copyEntity(e map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(e))
+
+	for k, v := range e {
+		c[k] = copyValue(v)
+	}
+
+	return c
+}
+
+func copyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return copyEntity(value)
+	case []interface{}:
+		list := make([]interface{}, len(value))
+
+		for i, item := range value {
+			list[i] = copyValue(item)
+		}
+
+		return list
+	}
+
+	return v
+}
+
+// normalize converts values to the types JSON decodes them to, so stored
+// entities compare equal to the ones clients decode
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+
+		for k, nested := range value {
+			m[k] = normalize(nested)
+		}
+
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+
+		for k, nested := range value {
+			m[fmt.Sprint(k)] = normalize(nested)
+		}
+
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+
+		for i, item := range value {
+			list[i] = normalize(item)
+		}
+
+		return list
+	case []string:
+		list := make([]interface{}, len(value))
+
+		for i, item := range value {
+			list[i] = item
+		}
+
+		return list
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	}
+
+	return v
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
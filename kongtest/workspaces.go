@@ -0,0 +1,105 @@
+package kongtest
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// defaultWorkspace is the workspace of Kong Enterprise serving requests made outside of any workspace
+const defaultWorkspace = "default"
+
+// workspace is a workspace of Kong Enterprise, with entities of its own
+type workspace struct {
+	entity map[string]interface{}
+	store  *store
+}
+
+func newWorkspace(name string, st *store) *workspace {
+	return &workspace{
+		entity: map[string]interface{}{"id": newUUID(), "name": name, "created_at": float64(time.Now().Unix())},
+		store:  st,
+	}
+}
+
+// resetWorkspaces creates the workspaces the server starts with, the default
+// one holding the entities served outside of any workspace
+func (s *Server) resetWorkspaces() {
+	s.workspaces = make(map[string]*workspace)
+
+	if !s.opts.Enterprise {
+		return
+	}
+
+	s.workspaces[defaultWorkspace] = newWorkspace(defaultWorkspace, s.store)
+
+	for _, name := range s.opts.Workspaces {
+		s.workspaces[name] = newWorkspace(name, newStore(s))
+	}
+}
+
+// workspaceEndpoint serves /workspaces and /workspaces/{name}
+func (s *Server) workspaceEndpoint(r *http.Request, segments []string, body map[string]interface{}) (int, interface{}) {
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		names := []string{}
+
+		for name := range s.workspaces {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		data := []map[string]interface{}{}
+
+		for _, name := range names {
+			data = append(data, s.workspaces[name].entity)
+		}
+
+		return http.StatusOK, map[string]interface{}{"data": data, "next": nil}
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		name, _ := body["name"].(string)
+
+		if name == "" {
+			return schemaViolation(map[string]interface{}{"name": "required field missing"})
+		}
+
+		if _, ok := s.workspaces[name]; ok {
+			err := uniqueViolationError(map[string]interface{}{"name": name})
+			return err.status, err.body
+		}
+
+		ws := newWorkspace(name, newStore(s))
+		s.workspaces[name] = ws
+
+		return http.StatusCreated, ws.entity
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		ws, ok := s.workspaces[segments[0]]
+
+		if !ok {
+			return notFound()
+		}
+
+		return http.StatusOK, ws.entity
+	case len(segments) == 1:
+		return methodNotAllowed()
+	}
+
+	return notFound()
+}
+
+// WorkspaceEntities returns a copy of the entities of a collection in a
+// workspace, with Options.Enterprise. It is empty when the workspace doesn't exist
+func (s *Server) WorkspaceEntities(workspace, collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities := []map[string]interface{}{}
+
+	if ws, ok := s.workspaces[workspace]; ok {
+		for _, e := range ws.store.entities[collection] {
+			entities = append(entities, copyEntity(e))
+		}
+	}
+
+	return entities
+}